@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/kljensen/snowball/spanish"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Search modes accepted by the search endpoints.
+const (
+	// SearchExact matches the query as written, accents and all, ignoring
+	// only case.
+	SearchExact = "exact"
+	// SearchAccentInsensitive matches the query ignoring case and accents,
+	// and "ñ" too unless SearchConfig.PreserveEnye is set.
+	SearchAccentInsensitive = "accent-insensitive"
+	// SearchStemmed matches verses containing every word of the query, or
+	// another inflection sharing its Snowball stem, in any order.
+	SearchStemmed = "stemmed"
+	// SearchFuzzy matches verses containing every word of the query in any
+	// order, replacing the words that are not in the text with the
+	// vocabulary words they are probably misspellings of.
+	SearchFuzzy = "fuzzy"
+)
+
+// analyzer turns Spanish text into the forms the search index stores and
+// queries are compared against. The same analyzer must be used to build the
+// index and to parse queries.
+type analyzer struct {
+	preserveEnye bool
+	stopwords    bool
+}
+
+// searchAnalyzer is the analyzer set from the configuration at startup.
+var searchAnalyzer = analyzer{preserveEnye: true, stopwords: true}
+
+func setupSearch(cfg SearchConfig) {
+	searchAnalyzer = analyzer{preserveEnye: cfg.PreserveEnye, stopwords: cfg.Stopwords}
+}
+
+// signature identifies the analyzer settings, so the index is rebuilt when
+// they change.
+func (a analyzer) signature() string {
+	return fmt.Sprintf("v1 enye=%t stopwords=%t", a.preserveEnye, a.stopwords)
+}
+
+// fold lowercases s and removes its accents, keeping "ñ" if preserveEnye is
+// set.
+func (a analyzer) fold(s string) string {
+	s = strings.ToLower(norm.NFC.String(s))
+	if !a.preserveEnye {
+		return removeAccents(s)
+	}
+	parts := strings.Split(s, "ñ")
+	for i, part := range parts {
+		parts[i] = removeAccents(part)
+	}
+	return strings.Join(parts, "ñ")
+}
+
+// words splits s into its words, dropping punctuation.
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stems returns the folded Snowball stems of the words of s, without
+// stopwords if they are ignored.
+func (a analyzer) stems(s string) []string {
+	stems := []string{}
+	for _, word := range words(strings.ToLower(norm.NFC.String(s))) {
+		if a.stopwords && spanish.IsStopWord(word) {
+			continue
+		}
+		stems = append(stems, a.fold(spanish.Stem(word, true)))
+	}
+	return stems
+}
+
+// escapeLike escapes the LIKE wildcards in s, for use with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// searchCondition returns the joins and WHERE clause that select the verses
+// matching query in mode, and their arguments. Queries using NEAR/n in the
+// accent-insensitive and stemmed modes are proximity searches.
+func searchCondition(query, mode string) (string, []any) {
+	const join = ` JOIN verses_analyzed ON verses_analyzed.rowid = verses.rowid WHERE `
+	if hasNearOperator(query) && (mode == SearchAccentInsensitive || mode == SearchStemmed) {
+		if q, err := parseProximity(query, mode); err == nil {
+			return join + `verses_analyzed MATCH ?`, []any{q.match(mode)}
+		}
+		// Requests are validated before they get here; any other caller
+		// searches for the query as written.
+	}
+	switch mode {
+	case SearchExact:
+		return join + `verses_analyzed.lowered LIKE ? ESCAPE '\'`, []any{"%" + escapeLike(strings.ToLower(norm.NFC.String(query))) + "%"}
+	case SearchStemmed:
+		terms := []string{}
+		for _, stem := range searchAnalyzer.stems(query) {
+			terms = append(terms, `"`+stem+`"`)
+		}
+		if len(terms) > 0 {
+			return join + `verses_analyzed MATCH ?`, []any{"stems : (" + strings.Join(terms, " AND ") + ")"}
+		}
+		// The query is all stopwords; look for it as a phrase instead.
+	case SearchFuzzy:
+		if expression := searchVocabulary.matchExpression(query); expression != "" {
+			return join + `verses_analyzed MATCH ?`, []any{"folded : (" + expression + ")"}
+		}
+		// The query has no words; look for it as written instead.
+	}
+	return join + `verses_analyzed.folded LIKE ? ESCAPE '\'`, []any{"%" + escapeLike(searchAnalyzer.fold(query)) + "%"}
+}
+
+// ensureSearchIndex fills verses_analyzed with the analyzed text of every
+// verse, unless it was already built with the same analyzer settings.
+func ensureSearchIndex(ctx context.Context, db *sqlx.DB, a analyzer) error {
+	var built string
+	err := db.GetContext(ctx, &built, `SELECT coalesce(max(analyzer), '') FROM search_index`)
+	if err != nil {
+		return fmt.Errorf("error while reading search index settings: %v", err)
+	}
+	if built == a.signature() {
+		return nil
+	}
+	slog.Info("building search index", "analyzer", a.signature())
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	verses := []struct {
+		RowID     int64  `db:"rowid"`
+		CleanText string `db:"cleanText"`
+	}{}
+	if err := tx.SelectContext(ctx, &verses, `SELECT rowid, cleanText FROM verses`); err != nil {
+		return fmt.Errorf("error while getting verses from DB: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM verses_analyzed`); err != nil {
+		return err
+	}
+	insert, err := tx.PreparexContext(ctx, `INSERT INTO verses_analyzed (rowid, lowered, folded, stems) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, v := range verses {
+		lowered := strings.ToLower(norm.NFC.String(v.CleanText))
+		if _, err := insert.ExecContext(ctx, v.RowID, lowered, a.fold(v.CleanText), strings.Join(a.stems(v.CleanText), " ")); err != nil {
+			return fmt.Errorf("error while indexing verse %d: %v", v.RowID, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM search_index`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO search_index (analyzer) VALUES (?)`, a.signature()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// searchIndexReady fails unless the search index was built with the current
+// analyzer settings and its vocabulary is loaded.
+func searchIndexReady(ctx context.Context, db *sqlx.DB) error {
+	var built string
+	err := dbGet(ctx, db, "search_index", &built, `SELECT coalesce(max(analyzer), '') FROM search_index`)
+	if err != nil {
+		return err
+	}
+	if built != searchAnalyzer.signature() {
+		return fmt.Errorf("search index was built with %q, expected %q", built, searchAnalyzer.signature())
+	}
+	if len(searchVocabulary.words) == 0 {
+		return errors.New("search vocabulary is empty")
+	}
+	return nil
+}
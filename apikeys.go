@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+// Scopes an API key can be granted. Every operation that is not public
+// declares the scope it requires in its OpenAPI security requirements.
+const (
+	ScopeRead   = "read"
+	ScopeSearch = "search"
+	ScopeExport = "export"
+	ScopeAdmin  = "admin"
+)
+
+var knownScopes = []string{ScopeRead, ScopeSearch, ScopeExport, ScopeAdmin}
+
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyQuery  = "api_key"
+	// apiKeyPrefix starts every key so leaked keys are easy to recognize.
+	apiKeyPrefix = "bib_"
+)
+
+// APIKey is a partner key as stored in the api_keys table. Only the SHA-256
+// hash of the secret is kept; the key itself is shown once, when created.
+type APIKey struct {
+	ID         string         `db:"id"`
+	Name       string         `db:"name"`
+	Hash       string         `db:"hash"`
+	Scopes     string         `db:"scopes"`
+	DailyQuota int            `db:"daily_quota"`
+	CreatedAt  string         `db:"created_at"`
+	RevokedAt  sql.NullString `db:"revoked_at"`
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(strings.Split(k.Scopes, ","), scope)
+}
+
+// securitySchemes are the ways an API key can be passed, as declared in the
+// OpenAPI document.
+var securitySchemes = map[string]*huma.SecurityScheme{
+	"apiKeyHeader": {
+		Type:        "apiKey",
+		In:          "header",
+		Name:        apiKeyHeader,
+		Description: "Clave de API enviada en el encabezado X-API-Key.",
+	},
+	"apiKeyQuery": {
+		Type:        "apiKey",
+		In:          "query",
+		Name:        apiKeyQuery,
+		Description: "Clave de API enviada en el parámetro de consulta api_key.",
+	},
+}
+
+// requireScope returns the security requirements for an operation that
+// needs the given scope, accepting the key in either the header or the
+// query string.
+func requireScope(scope string) []map[string][]string {
+	return []map[string][]string{
+		{"apiKeyHeader": {scope}},
+		{"apiKeyQuery": {scope}},
+	}
+}
+
+// requiredScope returns the scope declared by requireScope on op, or "" if
+// the operation is public.
+func requiredScope(op *huma.Operation) string {
+	for _, requirement := range op.Security {
+		for _, scopes := range requirement {
+			if len(scopes) > 0 {
+				return scopes[0]
+			}
+		}
+	}
+	return ""
+}
+
+// hashAPIKey returns the hex SHA-256 of a key. Keys are long random strings,
+// so a fast unsalted hash is enough to keep them unusable if the database
+// leaks.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseAPIKey splits "bib_<id>_<secret>" into its ID.
+func parseAPIKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	return id, ok && id != "" && secret != ""
+}
+
+// lookupAPIKey returns the active key matching key, or an error if it is
+// malformed, unknown or revoked.
+func lookupAPIKey(ctx context.Context, db *sqlx.DB, key string) (*APIKey, error) {
+	id, ok := parseAPIKey(key)
+	if !ok {
+		return nil, errors.New("malformed API key")
+	}
+	apiKey := APIKey{}
+	err := dbGet(ctx, db, "api_key", &apiKey, `SELECT id, name, hash, scopes, daily_quota, created_at, revoked_at FROM api_keys WHERE id = ?`, id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("unknown API key")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while getting API key from DB: %v", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey.Hash), []byte(hashAPIKey(key))) != 1 {
+		return nil, errors.New("unknown API key")
+	}
+	if apiKey.RevokedAt.Valid {
+		return nil, errors.New("API key has been revoked")
+	}
+	return &apiKey, nil
+}
+
+// consumeQuota counts one request against the key's quota for the current
+// UTC day and reports whether it was within the quota.
+func consumeQuota(ctx context.Context, db *sqlx.DB, apiKey *APIKey) (bool, error) {
+	result, err := db.ExecContext(ctx, `INSERT INTO api_key_usage (key_id, day, count) VALUES (?, ?, 1)
+		ON CONFLICT (key_id, day) DO UPDATE SET count = count + 1 WHERE ? = 0 OR count < ?`,
+		apiKey.ID, time.Now().UTC().Format(time.DateOnly), apiKey.DailyQuota, apiKey.DailyQuota)
+	if err != nil {
+		return false, fmt.Errorf("error while recording API key usage: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// checkAccess decides whether a request that sent key, which may be empty,
+// can use scope. Requests without a key are allowed if the scope is one of
+// anonymousScopes; requests with a key must use an active key that has the
+// scope and has not used up its daily quota. The key and the scopes granted
+// are recorded in the request's requestInfo. A rejected request gets a
+// non-zero status and a message explaining why.
+func checkAccess(ctx context.Context, db *sqlx.DB, key, scope string, anonymousScopes []string) (int, string, error) {
+	info := requestInfoFrom(ctx)
+	if key == "" {
+		info.Scopes = anonymousScopes
+		if slices.Contains(anonymousScopes, scope) {
+			return 0, "", nil
+		}
+		return http.StatusUnauthorized, fmt.Sprintf("an API key with the %q scope is required", scope), nil
+	}
+
+	apiKey, err := lookupAPIKey(ctx, db, key)
+	if err != nil {
+		return http.StatusUnauthorized, err.Error(), nil
+	}
+	info.APIKeyID = apiKey.ID
+	info.Scopes = strings.Split(apiKey.Scopes, ",")
+	if !apiKey.HasScope(scope) {
+		return http.StatusForbidden, fmt.Sprintf("API key %s does not have the %q scope", apiKey.ID, scope), nil
+	}
+	ok, err := consumeQuota(ctx, db, apiKey)
+	if err != nil {
+		return http.StatusInternalServerError, "unexpected error while checking the API key quota", err
+	}
+	if !ok {
+		return http.StatusTooManyRequests, fmt.Sprintf("API key %s has used its daily quota of %d requests", apiKey.ID, apiKey.DailyQuota), nil
+	}
+	return 0, "", nil
+}
+
+// requestAPIKey returns the API key sent in the header or, failing that, the
+// query string of a request.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+	return r.URL.Query().Get(apiKeyQuery)
+}
+
+// authorize returns Huma middleware that enforces the scope each operation
+// declares, as decided by checkAccess.
+func authorize(api huma.API, db *sqlx.DB, anonymousScopes []string) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		scope := requiredScope(ctx.Operation())
+		if scope == "" {
+			next(ctx)
+			return
+		}
+		key := ctx.Header(apiKeyHeader)
+		if key == "" {
+			key = ctx.Query(apiKeyQuery)
+		}
+		status, message, err := checkAccess(ctx.Context(), db, key, scope, anonymousScopes)
+		if err != nil {
+			huma.WriteErr(api, ctx, status, message, err)
+			return
+		}
+		if status != 0 {
+			huma.WriteErr(api, ctx, status, message)
+			return
+		}
+		next(ctx)
+	}
+}
+
+// createAPIKey stores a new key and returns it. This is the only time the
+// full key is available.
+func createAPIKey(db *sqlx.DB, name string, scopes []string, dailyQuota int) (string, error) {
+	idBytes := make([]byte, 4)
+	secretBytes := make([]byte, 24)
+	rand.Read(idBytes)
+	rand.Read(secretBytes)
+	id := hex.EncodeToString(idBytes)
+	key := apiKeyPrefix + id + "_" + hex.EncodeToString(secretBytes)
+	_, err := db.Exec(`INSERT INTO api_keys (id, name, hash, scopes, daily_quota, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		id, name, hashAPIKey(key), strings.Join(scopes, ","), dailyQuota, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return "", fmt.Errorf("error while creating API key: %v", err)
+	}
+	return key, nil
+}
+
+// runKeys implements the `keys create|revoke|list` commands.
+func runKeys(db *sqlx.DB, args []string) int {
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "usage: keys create -name NAME [-scopes read,search] [-quota N]")
+		fmt.Fprintln(os.Stderr, "       keys revoke ID")
+		fmt.Fprintln(os.Stderr, "       keys list")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+		name := fs.String("name", "", "who the key is for")
+		scopes := []string{ScopeRead, ScopeSearch}
+		fs.Var(listValue{&scopes}, "scopes", "comma separated scopes: "+strings.Join(knownScopes, ", "))
+		quota := fs.Int("quota", 0, "requests allowed per UTC day, 0 for unlimited")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if *name == "" {
+			fmt.Fprintln(os.Stderr, "keys create: -name is required")
+			return 2
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(knownScopes, scope) {
+				fmt.Fprintf(os.Stderr, "keys create: unknown scope %q, expected one of %s\n", scope, strings.Join(knownScopes, ", "))
+				return 2
+			}
+		}
+		if *quota < 0 {
+			fmt.Fprintln(os.Stderr, "keys create: -quota must not be negative")
+			return 2
+		}
+		key, err := createAPIKey(db, *name, scopes, *quota)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(key)
+		fmt.Fprintln(os.Stderr, "Store this key now; it cannot be shown again.")
+		return 0
+	case "revoke":
+		if len(args) != 2 {
+			return usage()
+		}
+		result, err := db.Exec(`UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now().UTC().Format(time.RFC3339), args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error while revoking API key: %v\n", err)
+			return 1
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			fmt.Fprintf(os.Stderr, "no active API key with ID %s\n", args[1])
+			return 1
+		}
+		fmt.Printf("Revoked API key %s\n", args[1])
+		return 0
+	case "list":
+		keys := []struct {
+			APIKey
+			UsedToday int `db:"used_today"`
+		}{}
+		err := db.Select(&keys, `SELECT k.id, k.name, k.hash, k.scopes, k.daily_quota, k.created_at, k.revoked_at, coalesce(u.count, 0) AS used_today
+			FROM api_keys k LEFT JOIN api_key_usage u ON u.key_id = k.id AND u.day = ?
+			ORDER BY k.created_at`, time.Now().UTC().Format(time.DateOnly))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error while listing API keys: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSCOPES\tQUOTA\tUSED TODAY\tCREATED\tREVOKED")
+		for _, k := range keys {
+			quota := "unlimited"
+			if k.DailyQuota > 0 {
+				quota = fmt.Sprint(k.DailyQuota)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", k.ID, k.Name, k.Scopes, quota, k.UsedToday, k.CreatedAt, k.RevokedAt.String)
+		}
+		w.Flush()
+		return 0
+	default:
+		return usage()
+	}
+}
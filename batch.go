@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type BatchRequest struct {
+	VerseFieldsRequest
+	Body struct {
+		Refs []string `json:"refs" minItems:"1" maxItems:"200" doc:"Identificadores de versículos (spa-RVR1960:Gen.1.1), referencias (Juan 3:16) o rangos (Génesis 1:1-2:3, Salmos 1-2)."`
+	}
+}
+
+// BatchError explains why one reference of a batch could not be resolved.
+type BatchError struct {
+	Status int    `json:"status" doc:"Código HTTP equivalente: 400 si la referencia no es válida, 404 si no existe."`
+	Detail string `json:"detail"`
+}
+
+// BatchItem is the result for one reference of a batch: either its verses or
+// an error.
+type BatchItem struct {
+	Passage string      `json:"passage,omitempty" doc:"Referencia OSIS normalizada, ej: spa-RVR1960:Gen.1.1-Gen.1.3"`
+	Verses  []Verse     `json:"verses,omitempty"`
+	Error   *BatchError `json:"error,omitempty"`
+}
+
+type BatchResults struct {
+	Results map[string]BatchItem `json:"results" doc:"Resultados indexados por la referencia tal como fue enviada."`
+}
+
+// batchRef is a reference of a batch that parsed, waiting for its verses.
+type batchRef struct {
+	input   string
+	passage passage
+}
+
+// lookupBatch resolves every reference of a batch, with one query per book.
+// References that cannot be parsed or match no verses get an error instead
+// of failing the whole batch.
+func lookupBatch(ctx context.Context, db *sqlx.DB, refs []string, fields verseSelection) (map[string]BatchItem, error) {
+	books, err := loadBookIndex(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	results := map[string]BatchItem{}
+	byBook := map[string][]batchRef{}
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		p, err := parseReference(ref, books)
+		if err != nil {
+			results[ref] = BatchItem{Error: &BatchError{Status: http.StatusBadRequest, Detail: err.Error()}}
+			continue
+		}
+		byBook[p.BookID] = append(byBook[p.BookID], batchRef{input: ref, passage: p})
+	}
+
+	for bookID, bookRefs := range byBook {
+		conditions := []string{}
+		args := []any{bookID}
+		for _, ref := range bookRefs {
+			first, last := ref.passage.bounds()
+			conditions = append(conditions, "(chapterNumber BETWEEN ? AND ? AND chapterNumber * 1000 + verseNumber BETWEEN ? AND ?)")
+			args = append(args, ref.passage.StartChapter, ref.passage.EndChapter, first, last)
+		}
+		verses := []Verse{}
+		err := dbSelect(ctx, db, "verses_batch", &verses, `SELECT `+fields.columns("chapterNumber", "verseNumber")+` FROM verses
+			WHERE bookId = ? AND (`+strings.Join(conditions, " OR ")+`)
+			ORDER BY chapterNumber, verseNumber`, args...)
+		if err != nil {
+			return nil, err
+		}
+		// Match every reference before trimming fields, since matching
+		// needs the chapter and verse numbers.
+		matches := make([][]Verse, len(bookRefs))
+		for i, ref := range bookRefs {
+			matches[i] = Filter(verses, ref.passage.contains)
+		}
+		for i, ref := range bookRefs {
+			if len(matches[i]) == 0 {
+				results[ref.input] = BatchItem{
+					Passage: ref.passage.String(),
+					Error:   &BatchError{Status: http.StatusNotFound, Detail: "no verses found for " + ref.passage.String()},
+				}
+				continue
+			}
+			fields.apply(matches[i])
+			results[ref.input] = BatchItem{Passage: ref.passage.String(), Verses: matches[i]}
+		}
+	}
+	return results, nil
+}
+
+func registerBatchRoutes(api huma.API, db *sqlx.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-verses-batch",
+		Method:      http.MethodPost,
+		Path:        "/api/verses/batch",
+		Summary:     "Obtener varios versículos o rangos en una sola petición",
+		Description: "Recibe hasta 200 identificadores de versículos, referencias o rangos y devuelve los versículos de cada uno indexados por la referencia enviada. Las referencias inválidas o inexistentes devuelven un error propio sin afectar al resto.",
+		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeRead),
+	}, func(ctx context.Context, input *BatchRequest) (*SingleResponse[BatchResults], error) {
+		results, err := lookupBatch(ctx, db, input.Body.Refs, input.selection())
+		if err != nil {
+			return nil, huma.Error500InternalServerError("error while getting verses from DB", err)
+		}
+		return &SingleResponse[BatchResults]{
+			Body: BatchResults{Results: results},
+		}, nil
+	})
+}
@@ -0,0 +1,815 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: bible/v1/bible.proto
+
+// Lookups over the Reina-Valera 1960 text, served by the same binary and
+// database as the HTTP API.
+
+package biblepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Book struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the book, e.g. "spa-RVR1960:Gen".
+	Id    string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Order int32  `protobuf:"varint,3,opt,name=order,proto3" json:"order,omitempty"`
+	// "OT" or "NT".
+	Testament     string     `protobuf:"bytes,4,opt,name=testament,proto3" json:"testament,omitempty"`
+	Chapters      []*Chapter `protobuf:"bytes,5,rep,name=chapters,proto3" json:"chapters,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Book) Reset() {
+	*x = Book{}
+	mi := &file_bible_v1_bible_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Book) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Book) ProtoMessage() {}
+
+func (x *Book) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Book.ProtoReflect.Descriptor instead.
+func (*Book) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Book) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Book) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Book) GetOrder() int32 {
+	if x != nil {
+		return x.Order
+	}
+	return 0
+}
+
+func (x *Book) GetTestament() string {
+	if x != nil {
+		return x.Testament
+	}
+	return ""
+}
+
+func (x *Book) GetChapters() []*Chapter {
+	if x != nil {
+		return x.Chapters
+	}
+	return nil
+}
+
+type Chapter struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Number int32                  `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	// ID of the chapter, e.g. "spa-RVR1960:Gen.1".
+	Id string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	// ID of the last verse of the chapter.
+	OsisEnd       string `protobuf:"bytes,3,opt,name=osis_end,json=osisEnd,proto3" json:"osis_end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chapter) Reset() {
+	*x = Chapter{}
+	mi := &file_bible_v1_bible_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chapter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chapter) ProtoMessage() {}
+
+func (x *Chapter) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chapter.ProtoReflect.Descriptor instead.
+func (*Chapter) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Chapter) GetNumber() int32 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *Chapter) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Chapter) GetOsisEnd() string {
+	if x != nil {
+		return x.OsisEnd
+	}
+	return ""
+}
+
+type Verse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the verse, e.g. "spa-RVR1960:Gen.1.1".
+	Id        string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ChapterId string `protobuf:"bytes,2,opt,name=chapter_id,json=chapterId,proto3" json:"chapter_id,omitempty"`
+	CleanText string `protobuf:"bytes,3,opt,name=clean_text,json=cleanText,proto3" json:"clean_text,omitempty"`
+	// Human readable reference, e.g. "Génesis 1:1".
+	Reference     string `protobuf:"bytes,4,opt,name=reference,proto3" json:"reference,omitempty"`
+	Text          string `protobuf:"bytes,5,opt,name=text,proto3" json:"text,omitempty"`
+	ChapterNumber int32  `protobuf:"varint,6,opt,name=chapter_number,json=chapterNumber,proto3" json:"chapter_number,omitempty"`
+	VerseNumber   int32  `protobuf:"varint,7,opt,name=verse_number,json=verseNumber,proto3" json:"verse_number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Verse) Reset() {
+	*x = Verse{}
+	mi := &file_bible_v1_bible_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Verse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Verse) ProtoMessage() {}
+
+func (x *Verse) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Verse.ProtoReflect.Descriptor instead.
+func (*Verse) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Verse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Verse) GetChapterId() string {
+	if x != nil {
+		return x.ChapterId
+	}
+	return ""
+}
+
+func (x *Verse) GetCleanText() string {
+	if x != nil {
+		return x.CleanText
+	}
+	return ""
+}
+
+func (x *Verse) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+func (x *Verse) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Verse) GetChapterNumber() int32 {
+	if x != nil {
+		return x.ChapterNumber
+	}
+	return 0
+}
+
+func (x *Verse) GetVerseNumber() int32 {
+	if x != nil {
+		return x.VerseNumber
+	}
+	return 0
+}
+
+type ListBooksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBooksRequest) Reset() {
+	*x = ListBooksRequest{}
+	mi := &file_bible_v1_bible_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBooksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBooksRequest) ProtoMessage() {}
+
+func (x *ListBooksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBooksRequest.ProtoReflect.Descriptor instead.
+func (*ListBooksRequest) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{3}
+}
+
+type ListBooksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Books         []*Book                `protobuf:"bytes,1,rep,name=books,proto3" json:"books,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBooksResponse) Reset() {
+	*x = ListBooksResponse{}
+	mi := &file_bible_v1_bible_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBooksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBooksResponse) ProtoMessage() {}
+
+func (x *ListBooksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBooksResponse.ProtoReflect.Descriptor instead.
+func (*ListBooksResponse) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListBooksResponse) GetBooks() []*Book {
+	if x != nil {
+		return x.Books
+	}
+	return nil
+}
+
+type GetBookRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BookId        string                 `protobuf:"bytes,1,opt,name=book_id,json=bookId,proto3" json:"book_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBookRequest) Reset() {
+	*x = GetBookRequest{}
+	mi := &file_bible_v1_bible_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBookRequest) ProtoMessage() {}
+
+func (x *GetBookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBookRequest.ProtoReflect.Descriptor instead.
+func (*GetBookRequest) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetBookRequest) GetBookId() string {
+	if x != nil {
+		return x.BookId
+	}
+	return ""
+}
+
+type GetChapterRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BookId        string                 `protobuf:"bytes,1,opt,name=book_id,json=bookId,proto3" json:"book_id,omitempty"`
+	Chapter       int32                  `protobuf:"varint,2,opt,name=chapter,proto3" json:"chapter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChapterRequest) Reset() {
+	*x = GetChapterRequest{}
+	mi := &file_bible_v1_bible_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChapterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChapterRequest) ProtoMessage() {}
+
+func (x *GetChapterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChapterRequest.ProtoReflect.Descriptor instead.
+func (*GetChapterRequest) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetChapterRequest) GetBookId() string {
+	if x != nil {
+		return x.BookId
+	}
+	return ""
+}
+
+func (x *GetChapterRequest) GetChapter() int32 {
+	if x != nil {
+		return x.Chapter
+	}
+	return 0
+}
+
+type GetChapterResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chapter       *Chapter               `protobuf:"bytes,1,opt,name=chapter,proto3" json:"chapter,omitempty"`
+	Verses        []*Verse               `protobuf:"bytes,2,rep,name=verses,proto3" json:"verses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChapterResponse) Reset() {
+	*x = GetChapterResponse{}
+	mi := &file_bible_v1_bible_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChapterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChapterResponse) ProtoMessage() {}
+
+func (x *GetChapterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChapterResponse.ProtoReflect.Descriptor instead.
+func (*GetChapterResponse) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetChapterResponse) GetChapter() *Chapter {
+	if x != nil {
+		return x.Chapter
+	}
+	return nil
+}
+
+func (x *GetChapterResponse) GetVerses() []*Verse {
+	if x != nil {
+		return x.Verses
+	}
+	return nil
+}
+
+type GetVerseRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the verse, e.g. "spa-RVR1960:Gen.1.1".
+	Id            string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetVerseRequest) Reset() {
+	*x = GetVerseRequest{}
+	mi := &file_bible_v1_bible_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetVerseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVerseRequest) ProtoMessage() {}
+
+func (x *GetVerseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVerseRequest.ProtoReflect.Descriptor instead.
+func (*GetVerseRequest) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetVerseRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetRangeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// A verse ID, an OSIS range such as "spa-RVR1960:Gen.1.1-Gen.2.3", or a
+	// reference such as "Juan 3:16-18", "Génesis 1:1-2:3" or "Salmos 1-2".
+	Reference     string `protobuf:"bytes,1,opt,name=reference,proto3" json:"reference,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRangeRequest) Reset() {
+	*x = GetRangeRequest{}
+	mi := &file_bible_v1_bible_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRangeRequest) ProtoMessage() {}
+
+func (x *GetRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRangeRequest.ProtoReflect.Descriptor instead.
+func (*GetRangeRequest) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetRangeRequest) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+type GetRangeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The normalized OSIS reference of the range.
+	Passage       string   `protobuf:"bytes,1,opt,name=passage,proto3" json:"passage,omitempty"`
+	Verses        []*Verse `protobuf:"bytes,2,rep,name=verses,proto3" json:"verses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRangeResponse) Reset() {
+	*x = GetRangeResponse{}
+	mi := &file_bible_v1_bible_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRangeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRangeResponse) ProtoMessage() {}
+
+func (x *GetRangeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRangeResponse.ProtoReflect.Descriptor instead.
+func (*GetRangeResponse) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetRangeResponse) GetPassage() string {
+	if x != nil {
+		return x.Passage
+	}
+	return ""
+}
+
+func (x *GetRangeResponse) GetVerses() []*Verse {
+	if x != nil {
+		return x.Verses
+	}
+	return nil
+}
+
+type SearchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Query string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// Maximum number of verses to return; 0 means no limit.
+	Limit         int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_bible_v1_bible_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bible_v1_bible_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_bible_v1_bible_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+var File_bible_v1_bible_proto protoreflect.FileDescriptor
+
+const file_bible_v1_bible_proto_rawDesc = "" +
+	"\n" +
+	"\x14bible/v1/bible.proto\x12\bbible.v1\"\x8d\x01\n" +
+	"\x04Book\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05order\x18\x03 \x01(\x05R\x05order\x12\x1c\n" +
+	"\ttestament\x18\x04 \x01(\tR\ttestament\x12-\n" +
+	"\bchapters\x18\x05 \x03(\v2\x11.bible.v1.ChapterR\bchapters\"L\n" +
+	"\aChapter\x12\x16\n" +
+	"\x06number\x18\x01 \x01(\x05R\x06number\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x19\n" +
+	"\bosis_end\x18\x03 \x01(\tR\aosisEnd\"\xd1\x01\n" +
+	"\x05Verse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1d\n" +
+	"\n" +
+	"chapter_id\x18\x02 \x01(\tR\tchapterId\x12\x1d\n" +
+	"\n" +
+	"clean_text\x18\x03 \x01(\tR\tcleanText\x12\x1c\n" +
+	"\treference\x18\x04 \x01(\tR\treference\x12\x12\n" +
+	"\x04text\x18\x05 \x01(\tR\x04text\x12%\n" +
+	"\x0echapter_number\x18\x06 \x01(\x05R\rchapterNumber\x12!\n" +
+	"\fverse_number\x18\a \x01(\x05R\vverseNumber\"\x12\n" +
+	"\x10ListBooksRequest\"9\n" +
+	"\x11ListBooksResponse\x12$\n" +
+	"\x05books\x18\x01 \x03(\v2\x0e.bible.v1.BookR\x05books\")\n" +
+	"\x0eGetBookRequest\x12\x17\n" +
+	"\abook_id\x18\x01 \x01(\tR\x06bookId\"F\n" +
+	"\x11GetChapterRequest\x12\x17\n" +
+	"\abook_id\x18\x01 \x01(\tR\x06bookId\x12\x18\n" +
+	"\achapter\x18\x02 \x01(\x05R\achapter\"j\n" +
+	"\x12GetChapterResponse\x12+\n" +
+	"\achapter\x18\x01 \x01(\v2\x11.bible.v1.ChapterR\achapter\x12'\n" +
+	"\x06verses\x18\x02 \x03(\v2\x0f.bible.v1.VerseR\x06verses\"!\n" +
+	"\x0fGetVerseRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"/\n" +
+	"\x0fGetRangeRequest\x12\x1c\n" +
+	"\treference\x18\x01 \x01(\tR\treference\"U\n" +
+	"\x10GetRangeResponse\x12\x18\n" +
+	"\apassage\x18\x01 \x01(\tR\apassage\x12'\n" +
+	"\x06verses\x18\x02 \x03(\v2\x0f.bible.v1.VerseR\x06verses\";\n" +
+	"\rSearchRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit2\x83\x03\n" +
+	"\fBibleService\x12D\n" +
+	"\tListBooks\x12\x1a.bible.v1.ListBooksRequest\x1a\x1b.bible.v1.ListBooksResponse\x123\n" +
+	"\aGetBook\x12\x18.bible.v1.GetBookRequest\x1a\x0e.bible.v1.Book\x12G\n" +
+	"\n" +
+	"GetChapter\x12\x1b.bible.v1.GetChapterRequest\x1a\x1c.bible.v1.GetChapterResponse\x126\n" +
+	"\bGetVerse\x12\x19.bible.v1.GetVerseRequest\x1a\x0f.bible.v1.Verse\x12A\n" +
+	"\bGetRange\x12\x19.bible.v1.GetRangeRequest\x1a\x1a.bible.v1.GetRangeResponse\x124\n" +
+	"\x06Search\x12\x17.bible.v1.SearchRequest\x1a\x0f.bible.v1.Verse0\x01BBZ@github.com/samueldelacruz/spanish-bible-api-demo/biblepb;biblepbb\x06proto3"
+
+var (
+	file_bible_v1_bible_proto_rawDescOnce sync.Once
+	file_bible_v1_bible_proto_rawDescData []byte
+)
+
+func file_bible_v1_bible_proto_rawDescGZIP() []byte {
+	file_bible_v1_bible_proto_rawDescOnce.Do(func() {
+		file_bible_v1_bible_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_bible_v1_bible_proto_rawDesc), len(file_bible_v1_bible_proto_rawDesc)))
+	})
+	return file_bible_v1_bible_proto_rawDescData
+}
+
+var file_bible_v1_bible_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_bible_v1_bible_proto_goTypes = []any{
+	(*Book)(nil),               // 0: bible.v1.Book
+	(*Chapter)(nil),            // 1: bible.v1.Chapter
+	(*Verse)(nil),              // 2: bible.v1.Verse
+	(*ListBooksRequest)(nil),   // 3: bible.v1.ListBooksRequest
+	(*ListBooksResponse)(nil),  // 4: bible.v1.ListBooksResponse
+	(*GetBookRequest)(nil),     // 5: bible.v1.GetBookRequest
+	(*GetChapterRequest)(nil),  // 6: bible.v1.GetChapterRequest
+	(*GetChapterResponse)(nil), // 7: bible.v1.GetChapterResponse
+	(*GetVerseRequest)(nil),    // 8: bible.v1.GetVerseRequest
+	(*GetRangeRequest)(nil),    // 9: bible.v1.GetRangeRequest
+	(*GetRangeResponse)(nil),   // 10: bible.v1.GetRangeResponse
+	(*SearchRequest)(nil),      // 11: bible.v1.SearchRequest
+}
+var file_bible_v1_bible_proto_depIdxs = []int32{
+	1,  // 0: bible.v1.Book.chapters:type_name -> bible.v1.Chapter
+	0,  // 1: bible.v1.ListBooksResponse.books:type_name -> bible.v1.Book
+	1,  // 2: bible.v1.GetChapterResponse.chapter:type_name -> bible.v1.Chapter
+	2,  // 3: bible.v1.GetChapterResponse.verses:type_name -> bible.v1.Verse
+	2,  // 4: bible.v1.GetRangeResponse.verses:type_name -> bible.v1.Verse
+	3,  // 5: bible.v1.BibleService.ListBooks:input_type -> bible.v1.ListBooksRequest
+	5,  // 6: bible.v1.BibleService.GetBook:input_type -> bible.v1.GetBookRequest
+	6,  // 7: bible.v1.BibleService.GetChapter:input_type -> bible.v1.GetChapterRequest
+	8,  // 8: bible.v1.BibleService.GetVerse:input_type -> bible.v1.GetVerseRequest
+	9,  // 9: bible.v1.BibleService.GetRange:input_type -> bible.v1.GetRangeRequest
+	11, // 10: bible.v1.BibleService.Search:input_type -> bible.v1.SearchRequest
+	4,  // 11: bible.v1.BibleService.ListBooks:output_type -> bible.v1.ListBooksResponse
+	0,  // 12: bible.v1.BibleService.GetBook:output_type -> bible.v1.Book
+	7,  // 13: bible.v1.BibleService.GetChapter:output_type -> bible.v1.GetChapterResponse
+	2,  // 14: bible.v1.BibleService.GetVerse:output_type -> bible.v1.Verse
+	10, // 15: bible.v1.BibleService.GetRange:output_type -> bible.v1.GetRangeResponse
+	2,  // 16: bible.v1.BibleService.Search:output_type -> bible.v1.Verse
+	11, // [11:17] is the sub-list for method output_type
+	5,  // [5:11] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_bible_v1_bible_proto_init() }
+func file_bible_v1_bible_proto_init() {
+	if File_bible_v1_bible_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_bible_v1_bible_proto_rawDesc), len(file_bible_v1_bible_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_bible_v1_bible_proto_goTypes,
+		DependencyIndexes: file_bible_v1_bible_proto_depIdxs,
+		MessageInfos:      file_bible_v1_bible_proto_msgTypes,
+	}.Build()
+	File_bible_v1_bible_proto = out.File
+	file_bible_v1_bible_proto_goTypes = nil
+	file_bible_v1_bible_proto_depIdxs = nil
+}
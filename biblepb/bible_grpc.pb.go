@@ -0,0 +1,332 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: bible/v1/bible.proto
+
+// Lookups over the Reina-Valera 1960 text, served by the same binary and
+// database as the HTTP API.
+
+package biblepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	BibleService_ListBooks_FullMethodName  = "/bible.v1.BibleService/ListBooks"
+	BibleService_GetBook_FullMethodName    = "/bible.v1.BibleService/GetBook"
+	BibleService_GetChapter_FullMethodName = "/bible.v1.BibleService/GetChapter"
+	BibleService_GetVerse_FullMethodName   = "/bible.v1.BibleService/GetVerse"
+	BibleService_GetRange_FullMethodName   = "/bible.v1.BibleService/GetRange"
+	BibleService_Search_FullMethodName     = "/bible.v1.BibleService/Search"
+)
+
+// BibleServiceClient is the client API for BibleService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BibleServiceClient interface {
+	// ListBooks returns every book in canonical order with its chapters.
+	ListBooks(ctx context.Context, in *ListBooksRequest, opts ...grpc.CallOption) (*ListBooksResponse, error)
+	// GetBook returns a book with its chapters.
+	GetBook(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (*Book, error)
+	// GetChapter returns a chapter and its verses.
+	GetChapter(ctx context.Context, in *GetChapterRequest, opts ...grpc.CallOption) (*GetChapterResponse, error)
+	// GetVerse returns a single verse.
+	GetVerse(ctx context.Context, in *GetVerseRequest, opts ...grpc.CallOption) (*Verse, error)
+	// GetRange returns the verses of a reference or range.
+	GetRange(ctx context.Context, in *GetRangeRequest, opts ...grpc.CallOption) (*GetRangeResponse, error)
+	// Search streams the verses containing a text, ignoring accents, as they
+	// are read from the database.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Verse], error)
+}
+
+type bibleServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBibleServiceClient(cc grpc.ClientConnInterface) BibleServiceClient {
+	return &bibleServiceClient{cc}
+}
+
+func (c *bibleServiceClient) ListBooks(ctx context.Context, in *ListBooksRequest, opts ...grpc.CallOption) (*ListBooksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListBooksResponse)
+	err := c.cc.Invoke(ctx, BibleService_ListBooks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bibleServiceClient) GetBook(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (*Book, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Book)
+	err := c.cc.Invoke(ctx, BibleService_GetBook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bibleServiceClient) GetChapter(ctx context.Context, in *GetChapterRequest, opts ...grpc.CallOption) (*GetChapterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetChapterResponse)
+	err := c.cc.Invoke(ctx, BibleService_GetChapter_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bibleServiceClient) GetVerse(ctx context.Context, in *GetVerseRequest, opts ...grpc.CallOption) (*Verse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Verse)
+	err := c.cc.Invoke(ctx, BibleService_GetVerse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bibleServiceClient) GetRange(ctx context.Context, in *GetRangeRequest, opts ...grpc.CallOption) (*GetRangeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRangeResponse)
+	err := c.cc.Invoke(ctx, BibleService_GetRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bibleServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Verse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BibleService_ServiceDesc.Streams[0], BibleService_Search_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SearchRequest, Verse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BibleService_SearchClient = grpc.ServerStreamingClient[Verse]
+
+// BibleServiceServer is the server API for BibleService service.
+// All implementations must embed UnimplementedBibleServiceServer
+// for forward compatibility.
+type BibleServiceServer interface {
+	// ListBooks returns every book in canonical order with its chapters.
+	ListBooks(context.Context, *ListBooksRequest) (*ListBooksResponse, error)
+	// GetBook returns a book with its chapters.
+	GetBook(context.Context, *GetBookRequest) (*Book, error)
+	// GetChapter returns a chapter and its verses.
+	GetChapter(context.Context, *GetChapterRequest) (*GetChapterResponse, error)
+	// GetVerse returns a single verse.
+	GetVerse(context.Context, *GetVerseRequest) (*Verse, error)
+	// GetRange returns the verses of a reference or range.
+	GetRange(context.Context, *GetRangeRequest) (*GetRangeResponse, error)
+	// Search streams the verses containing a text, ignoring accents, as they
+	// are read from the database.
+	Search(*SearchRequest, grpc.ServerStreamingServer[Verse]) error
+	mustEmbedUnimplementedBibleServiceServer()
+}
+
+// UnimplementedBibleServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBibleServiceServer struct{}
+
+func (UnimplementedBibleServiceServer) ListBooks(context.Context, *ListBooksRequest) (*ListBooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBooks not implemented")
+}
+func (UnimplementedBibleServiceServer) GetBook(context.Context, *GetBookRequest) (*Book, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBook not implemented")
+}
+func (UnimplementedBibleServiceServer) GetChapter(context.Context, *GetChapterRequest) (*GetChapterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChapter not implemented")
+}
+func (UnimplementedBibleServiceServer) GetVerse(context.Context, *GetVerseRequest) (*Verse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVerse not implemented")
+}
+func (UnimplementedBibleServiceServer) GetRange(context.Context, *GetRangeRequest) (*GetRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRange not implemented")
+}
+func (UnimplementedBibleServiceServer) Search(*SearchRequest, grpc.ServerStreamingServer[Verse]) error {
+	return status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedBibleServiceServer) mustEmbedUnimplementedBibleServiceServer() {}
+func (UnimplementedBibleServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeBibleServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BibleServiceServer will
+// result in compilation errors.
+type UnsafeBibleServiceServer interface {
+	mustEmbedUnimplementedBibleServiceServer()
+}
+
+func RegisterBibleServiceServer(s grpc.ServiceRegistrar, srv BibleServiceServer) {
+	// If the following call pancis, it indicates UnimplementedBibleServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&BibleService_ServiceDesc, srv)
+}
+
+func _BibleService_ListBooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BibleServiceServer).ListBooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BibleService_ListBooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BibleServiceServer).ListBooks(ctx, req.(*ListBooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BibleService_GetBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BibleServiceServer).GetBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BibleService_GetBook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BibleServiceServer).GetBook(ctx, req.(*GetBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BibleService_GetChapter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChapterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BibleServiceServer).GetChapter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BibleService_GetChapter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BibleServiceServer).GetChapter(ctx, req.(*GetChapterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BibleService_GetVerse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVerseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BibleServiceServer).GetVerse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BibleService_GetVerse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BibleServiceServer).GetVerse(ctx, req.(*GetVerseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BibleService_GetRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BibleServiceServer).GetRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BibleService_GetRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BibleServiceServer).GetRange(ctx, req.(*GetRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BibleService_Search_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BibleServiceServer).Search(m, &grpc.GenericServerStream[SearchRequest, Verse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BibleService_SearchServer = grpc.ServerStreamingServer[Verse]
+
+// BibleService_ServiceDesc is the grpc.ServiceDesc for BibleService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BibleService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bible.v1.BibleService",
+	HandlerType: (*BibleServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListBooks",
+			Handler:    _BibleService_ListBooks_Handler,
+		},
+		{
+			MethodName: "GetBook",
+			Handler:    _BibleService_GetBook_Handler,
+		},
+		{
+			MethodName: "GetChapter",
+			Handler:    _BibleService_GetChapter_Handler,
+		},
+		{
+			MethodName: "GetVerse",
+			Handler:    _BibleService_GetVerse_Handler,
+		},
+		{
+			MethodName: "GetRange",
+			Handler:    _BibleService_GetRange_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Search",
+			Handler:       _BibleService_Search_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "bible/v1/bible.proto",
+}
@@ -0,0 +1,14 @@
+// Package biblepb contains the messages and the gRPC client and server
+// interfaces of the Bible service defined in proto/bible/v1/bible.proto.
+// Other services can import it as their client:
+//
+//	conn, err := grpc.NewClient("bible-api:9090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	if err != nil {
+//		return err
+//	}
+//	client := biblepb.NewBibleServiceClient(conn)
+//	ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", apiKey)
+//	verse, err := client.GetVerse(ctx, &biblepb.GetVerseRequest{Id: "spa-RVR1960:John.3.16"})
+package biblepb
+
+//go:generate protoc -I ../proto --go_out=.. --go_opt=module=github.com/samueldelacruz/spanish-bible-api-demo --go-grpc_out=.. --go-grpc_opt=module=github.com/samueldelacruz/spanish-bible-api-demo bible/v1/bible.proto
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is an in-memory cache, safe for concurrent use, that keeps at most
+// maxEntries values and evicts the least recently used one when full. Lookups
+// are counted in the cache_lookups_total metric under its name.
+type lruCache[K comparable, V any] struct {
+	name       string
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[K]*list.Element
+	order   *list.List
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// newLRUCache returns an empty cache. A maxEntries of zero disables it: every
+// lookup misses and nothing is stored.
+func newLRUCache[K comparable, V any](name string, maxEntries int) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		name:       name,
+		maxEntries: maxEntries,
+		entries:    map[K]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+func (c *lruCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	recordCacheLookup(c.name, ok)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry[K, V]).value, true
+}
+
+func (c *lruCache[K, V]) Add(key K, value V) {
+	if c.maxEntries <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruEntry[K, V]).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// knownEncodings are the content codings the server can produce, in its
+// default order of preference.
+var knownEncodings = []string{"zstd", "br", "gzip"}
+
+// Cache-Control values sent with successful responses of immutable
+// operations: the text of a translation never changes once published.
+// Responses to requests made with an API key are private, so shared caches
+// do not hand them to clients without one, and public ones vary on the key
+// header so keyed requests still reach the server and count against quotas.
+const (
+	immutableCacheControl        = "public, max-age=86400, immutable"
+	privateImmutableCacheControl = "private, max-age=86400, immutable"
+)
+
+// markImmutable returns Huma middleware that flags GET operations that only
+// read Bible text, other than streams and mutable operations, as immutable,
+// so cacheImmutable sends their successful responses with
+// immutableCacheControl. With compression enabled, they are also
+// pre-compressed once, and those already in its cache are written without
+// calling the handler. It runs after authorize, so cached responses are
+// still authorized and counted.
+func markImmutable(c *compressor) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		op := ctx.Operation()
+		if op.Method != http.MethodGet || requiredScope(op) != ScopeRead || op.Metadata[streamingOperation] != nil || isMutable(ctx) {
+			next(ctx)
+			return
+		}
+		requestInfoFrom(ctx.Context()).Immutable = true
+		if c == nil {
+			next(ctx)
+			return
+		}
+		encoding := negotiateEncoding(ctx.Header("Accept-Encoding"), c.cfg.Encodings)
+		u := ctx.URL()
+		cached, ok := c.cache.Get(responseKey(ctx.Method(), ctx.Host(), u.RequestURI(), encoding))
+		if !ok {
+			next(ctx)
+			return
+		}
+		for name, values := range cached.header {
+			for _, value := range values {
+				ctx.AppendHeader(name, value)
+			}
+		}
+		ctx.SetHeader("Content-Length", strconv.Itoa(len(cached.body)))
+		ctx.SetStatus(http.StatusOK)
+		ctx.BodyWriter().Write(cached.body)
+	}
+}
+
+// cacheImmutable is chi middleware that sets Cache-Control on successful
+// responses to requests flagged immutable by markImmutable. It runs whether
+// or not compression is enabled, outside it, so it also covers responses
+// served from the compressed cache.
+func cacheImmutable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// cacheControlWriter adds the immutable Cache-Control headers just before
+// the status is sent, once the handler has set the status.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.wroteHeader = true
+		info := requestInfoFrom(w.r.Context())
+		if info.Immutable && status == http.StatusOK && w.r.Method == http.MethodGet {
+			h := w.Header()
+			if info.APIKeyID != "" {
+				h.Set("Cache-Control", privateImmutableCacheControl)
+			} else {
+				h.Set("Cache-Control", immutableCacheControl)
+				h.Add("Vary", apiKeyHeader)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheControlWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush sends the status first, like net/http does, so it gets the headers.
+func (w *cacheControlWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// isMutable reports whether the operation of ctx, or this request of it, is
+// flagged with mutableOperation.
+func isMutable(ctx huma.Context) bool {
+	switch mutable := ctx.Operation().Metadata[mutableOperation].(type) {
+	case bool:
+		return mutable
+	case string:
+		value := ctx.Query(mutable)
+		return value != "" && value != "0"
+	}
+	return false
+}
+
+// encoder is implemented by the gzip, brotli and zstd writers.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+type encoderKey struct {
+	encoding string
+	// best selects the slow, high ratio level used for pre-compressed
+	// responses, rather than the fast one used when streaming.
+	best bool
+}
+
+func encoderPool(newEncoder func() encoder) *sync.Pool {
+	return &sync.Pool{New: func() any { return newEncoder() }}
+}
+
+var encoderPools = map[encoderKey]*sync.Pool{
+	{"gzip", false}: encoderPool(func() encoder {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}),
+	{"gzip", true}: encoderPool(func() encoder {
+		w, _ := gzip.NewWriterLevel(nil, gzip.BestCompression)
+		return w
+	}),
+	{"br", false}: encoderPool(func() encoder { return brotli.NewWriterLevel(nil, 4) }),
+	{"br", true}:  encoderPool(func() encoder { return brotli.NewWriterLevel(nil, 9) }),
+	{"zstd", false}: encoderPool(func() encoder {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return w
+	}),
+	{"zstd", true}: encoderPool(func() encoder {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression), zstd.WithEncoderConcurrency(1))
+		return w
+	}),
+}
+
+func getEncoder(encoding string, best bool, w io.Writer) encoder {
+	enc := encoderPools[encoderKey{encoding, best}].Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+func putEncoder(encoding string, best bool, enc encoder) {
+	enc.Reset(io.Discard)
+	encoderPools[encoderKey{encoding, best}].Put(enc)
+}
+
+// negotiateEncoding picks the encoding with the highest quality in an
+// Accept-Encoding header, preferring earlier entries of supported on ties.
+// It returns "" if the client accepts none of them.
+func negotiateEncoding(header string, supported []string) string {
+	qualities := map[string]float64{}
+	wildcard := 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = parsed
+			}
+		}
+		switch name {
+		case "":
+		case "*":
+			wildcard = quality
+		case "x-gzip":
+			qualities["gzip"] = quality
+		default:
+			qualities[name] = quality
+		}
+	}
+	best, bestQuality := "", 0.0
+	for _, encoding := range supported {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality = wildcard
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressible reports whether a response of the given content type is worth
+// compressing. Event streams are left alone so every event reaches the client
+// as soon as it is written.
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/cbor", "application/yaml",
+		"application/xml", "application/javascript":
+		return true
+	}
+	return false
+}
+
+// cachedHeaders are the response headers set by handlers that are stored
+// along with cached responses. The rest are set again by the middleware on
+// every request.
+var cachedHeaders = []string{"Content-Type", "Content-Encoding", "Link"}
+
+// cachedResponse is the body of a successful response to an immutable
+// operation, compressed if it was big enough, with its cachedHeaders.
+type cachedResponse struct {
+	header http.Header
+	body   []byte
+}
+
+// responseKey identifies the cached response to a request. The host is part
+// of it because responses link to their schema on the host they were served
+// from.
+func responseKey(method, host, uri, encoding string) string {
+	return method + " " + host + uri + " " + encoding
+}
+
+// compressor negotiates a content coding for each response and compresses
+// it. Responses smaller than the minimum size are sent as they are, and
+// immutable ones are compressed once at the best level and then served from
+// a cache keyed by their URL and encoding.
+type compressor struct {
+	cfg   CompressionConfig
+	cache *lruCache[string, cachedResponse]
+}
+
+func newCompressor(cfg CompressionConfig, maxEntries int) *compressor {
+	return &compressor{
+		cfg:   cfg,
+		cache: newLRUCache[string, cachedResponse]("compressed_responses", maxEntries),
+	}
+}
+
+// compress returns body compressed with encoding, at the best level when
+// best is set.
+func (c *compressor) compress(encoding string, body []byte, best bool) []byte {
+	var out bytes.Buffer
+	enc := getEncoder(encoding, best, &out)
+	enc.Write(body)
+	enc.Close()
+	putEncoder(encoding, best, enc)
+	return out.Bytes()
+}
+
+// Middleware is chi middleware that compresses responses.
+func (c *compressor) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &compressWriter{
+			ResponseWriter: w,
+			c:              c,
+			r:              r,
+			encoding:       negotiateEncoding(r.Header.Get("Accept-Encoding"), c.cfg.Encodings),
+		}
+		next.ServeHTTP(cw, r)
+		cw.finish()
+	})
+}
+
+// compressWriter holds back the status and the start of the body until it
+// knows whether to compress: then it either passes writes straight through,
+// or buffers them until the minimum size is reached and streams the rest
+// through an encoder. Immutable responses are buffered whole so they can be
+// compressed at the best level and cached.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *compressor
+	r        *http.Request
+	encoding string
+
+	status    int
+	decided   bool
+	compress  bool
+	immutable bool
+	buf       []byte
+	enc       encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	cw.status = status
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.decide()
+	}
+}
+
+// decide chooses between compressing and passing the response through, once
+// the handler has set its headers and status.
+func (cw *compressWriter) decide() {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	h := cw.Header()
+	cw.immutable = requestInfoFrom(cw.r.Context()).Immutable && cw.status == http.StatusOK && cw.r.Method == http.MethodGet
+	bodyAllowed := cw.status >= http.StatusOK && cw.status != http.StatusNoContent && cw.status != http.StatusNotModified
+	if !bodyAllowed || cw.r.Method == http.MethodHead || !compressible(h.Get("Content-Type")) {
+		cw.ResponseWriter.WriteHeader(cw.status)
+		return
+	}
+	h.Add("Vary", "Accept-Encoding")
+	if cw.encoding == "" || h.Get("Content-Encoding") != "" {
+		cw.ResponseWriter.WriteHeader(cw.status)
+		return
+	}
+	cw.compress = true
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.decide()
+	}
+	switch {
+	case !cw.compress:
+		return cw.ResponseWriter.Write(p)
+	case cw.enc != nil:
+		return cw.enc.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if !cw.immutable && len(cw.buf) >= cw.c.cfg.MinSize {
+		if err := cw.startStream(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// startStream sends the headers and switches to streaming the body through
+// an encoder, starting with what has been buffered so far.
+func (cw *compressWriter) startStream() error {
+	h := cw.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", cw.encoding)
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.enc = getEncoder(cw.encoding, false, cw.ResponseWriter)
+	_, err := cw.enc.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+// finish writes whatever the handler left buffered once it returns.
+func (cw *compressWriter) finish() {
+	if !cw.decided {
+		if cw.status == 0 {
+			// Nothing was written; net/http sends an empty 200.
+			return
+		}
+		cw.decide()
+	}
+	if !cw.compress {
+		return
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+		putEncoder(cw.encoding, false, cw.enc)
+		cw.enc = nil
+		return
+	}
+	body := cw.buf
+	if len(body) >= cw.c.cfg.MinSize && len(body) > 0 {
+		body = cw.c.compress(cw.encoding, body, cw.immutable)
+		cw.Header().Set("Content-Encoding", cw.encoding)
+	}
+	if cw.immutable {
+		cached := cachedResponse{header: http.Header{}, body: body}
+		for _, name := range cachedHeaders {
+			if values := cw.Header().Values(name); len(values) > 0 {
+				cached.header[name] = values
+			}
+		}
+		cw.c.cache.Add(responseKey(cw.r.Method, cw.r.Host, cw.r.URL.RequestURI(), cw.encoding), cached)
+	}
+	cw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.ResponseWriter.Write(body)
+}
+
+// Flush sends what has been written so far, compressed if needed, so
+// streaming responses are not held back by the encoder.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide()
+	}
+	if cw.compress && cw.enc == nil {
+		cw.startStream()
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
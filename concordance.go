@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+// The concordance lists every occurrence of a word, like a printed
+// concordance, from the folded words of the search index. Words are
+// normalized like accent-insensitive searches: lowercase and without
+// accents, keeping "ñ" if the analyzer preserves it.
+
+// concordanceContext is the number of words shown on each side of an
+// occurrence.
+const concordanceContext = 4
+
+// ConcordanceOccurrence is one occurrence of a word in a verse.
+type ConcordanceOccurrence struct {
+	VerseID   string `json:"verseId" db:"id" doc:"Id del versículo"`
+	Reference string `json:"reference" db:"reference" doc:"Referencia del versículo, ej: Juan 3:16"`
+	Chapter   int    `json:"chapter" db:"chapterNumber" doc:"Número de capítulo"`
+	Verse     int    `json:"verse" db:"verseNumber" doc:"Número de versículo"`
+	Position  int    `json:"position" doc:"Posición de la palabra en el versículo, contando palabras desde 1"`
+	Form      string `json:"form" doc:"La palabra como está escrita en el versículo, con acentos y mayúsculas"`
+	Context   string `json:"context" doc:"Las palabras que rodean a la palabra en el versículo"`
+}
+
+// ConcordanceBook is the occurrences of a word in a book.
+type ConcordanceBook struct {
+	BookID      string                  `json:"bookId" doc:"Id del libro"`
+	Name        string                  `json:"name" doc:"Nombre del libro"`
+	Count       int                     `json:"count" doc:"Número de apariciones en el libro"`
+	Occurrences []ConcordanceOccurrence `json:"occurrences,omitempty" doc:"Las apariciones en el libro de la página pedida, en orden, si se pidieron"`
+}
+
+type Concordance struct {
+	Word   string            `json:"word" doc:"La palabra normalizada"`
+	Count  int               `json:"count" doc:"Número total de apariciones"`
+	Verses int               `json:"verses" doc:"Número de versículos donde aparece"`
+	Books  []ConcordanceBook `json:"books" doc:"Las apariciones agrupadas por libro, en orden canónico"`
+	// Truncated is only set when occurrences are returned.
+	Truncated bool `json:"truncated" doc:"true si hay más versículos con apariciones después de los devueltos"`
+}
+
+type ConcordanceRequest struct {
+	Word        string `path:"word" maxLength:"50" doc:"Palabra a buscar; no distingue mayúsculas ni acentos"`
+	Book        string `query:"book" doc:"Id del libro al que limitar la concordancia, ej: spa-RVR1960:John; todos si se omite"`
+	Occurrences bool   `query:"occurrences" default:"true" doc:"false para devolver solo el número de apariciones por libro"`
+	After       string `query:"after" doc:"Devolver solo las apariciones en los versículos que van después de este, para pedir la página siguiente con el id del último versículo recibido"`
+	Limit       int    `query:"limit" minimum:"1" maximum:"1000" default:"100" doc:"Máximo de versículos con apariciones a devolver; los números de apariciones cuentan todos"`
+}
+
+type VocabularyRequest struct {
+	Prefix string `query:"prefix" maxLength:"50" doc:"Comienzo de las palabras a listar; no distingue mayúsculas ni acentos. Todas si se omite"`
+	After  string `query:"after" doc:"Listar solo las palabras que van después de esta, para pedir la página siguiente con la última palabra recibida"`
+	Limit  int    `query:"limit" minimum:"1" maximum:"1000" default:"100" doc:"Máximo de palabras a devolver"`
+}
+
+// VocabularyEntry is a word of the text and how often it occurs.
+type VocabularyEntry struct {
+	Word   string `json:"word" db:"term" doc:"La palabra normalizada"`
+	Verses int    `json:"verses" db:"doc" doc:"Número de versículos donde aparece"`
+	Count  int    `json:"count" db:"cnt" doc:"Número total de apariciones"`
+}
+
+// wordBounds returns the byte offsets of the words of s, split like words.
+func wordBounds(s string) [][2]int {
+	bounds := [][2]int{}
+	start := -1
+	for i, r := range s {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			bounds = append(bounds, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		bounds = append(bounds, [2]int{start, len(s)})
+	}
+	return bounds
+}
+
+// occurrences returns the occurrences of the folded word in text, with their
+// word positions, forms and context.
+func occurrences(text, word string) []ConcordanceOccurrence {
+	result := []ConcordanceOccurrence{}
+	bounds := wordBounds(text)
+	for i, b := range bounds {
+		form := text[b[0]:b[1]]
+		if searchAnalyzer.fold(form) != word {
+			continue
+		}
+		first, last := max(0, i-concordanceContext), min(len(bounds)-1, i+concordanceContext)
+		context := text[bounds[first][0]:bounds[last][1]]
+		if first > 0 {
+			context = "…" + context
+		}
+		if last < len(bounds)-1 {
+			context += "…"
+		}
+		result = append(result, ConcordanceOccurrence{Position: i + 1, Form: form, Context: context})
+	}
+	return result
+}
+
+// concordanceVerse is a verse containing the word, with its book.
+type concordanceVerse struct {
+	ConcordanceOccurrence
+	BookID    string `db:"bookId"`
+	BookName  string `db:"name"`
+	CleanText string `db:"cleanText"`
+	Ordinal   int    `db:"ordinal"`
+}
+
+// concordancePage selects the verses whose occurrences are returned: up to
+// limit of them after the verse with ordinal after, or from the start if it
+// is 0.
+type concordancePage struct {
+	after, limit int
+}
+
+// concordance returns the occurrences of the folded word, in bookId if it is
+// not empty, grouped by book in canonical order. The counts cover every
+// occurrence, and, when withOccurrences is set, the occurrences of the verses
+// in page are listed.
+func concordance(ctx context.Context, db *sqlx.DB, word, bookId string, withOccurrences bool, page concordancePage) (Concordance, error) {
+	result := Concordance{Word: word, Books: []ConcordanceBook{}}
+	listed := 0
+	query := `SELECT verses.id, verses.reference, verses.chapterNumber, verses.verseNumber, verses.bookId, verses.cleanText, verses.ordinal, books.name
+		FROM verses
+		JOIN verses_analyzed ON verses_analyzed.rowid = verses.rowid
+		JOIN books ON books.id = verses.bookId
+		WHERE verses_analyzed MATCH ?`
+	args := []any{`folded : "` + word + `"`}
+	if bookId != "" {
+		query += ` AND verses.bookId = ?`
+		args = append(args, bookId)
+	}
+	err := dbEach(ctx, db, "concordance", func(v concordanceVerse) error {
+		found := occurrences(v.CleanText, word)
+		if len(found) == 0 {
+			return nil
+		}
+		if len(result.Books) == 0 || result.Books[len(result.Books)-1].BookID != v.BookID {
+			result.Books = append(result.Books, ConcordanceBook{BookID: v.BookID, Name: v.BookName})
+		}
+		book := &result.Books[len(result.Books)-1]
+		book.Count += len(found)
+		result.Count += len(found)
+		result.Verses++
+		if !withOccurrences || v.Ordinal <= page.after {
+			return nil
+		}
+		if listed == page.limit {
+			result.Truncated = true
+			return nil
+		}
+		listed++
+		for _, o := range found {
+			o.VerseID, o.Reference, o.Chapter, o.Verse = v.VerseID, v.Reference, v.Chapter, v.Verse
+			book.Occurrences = append(book.Occurrences, o)
+		}
+		return nil
+	}, query+` ORDER BY verses.ordinal`, args...)
+	return result, err
+}
+
+// browseVocabulary returns up to limit words of the text starting with
+// prefix and sorting after after, in order.
+func browseVocabulary(ctx context.Context, db *sqlx.DB, prefix, after string, limit int) ([]VocabularyEntry, error) {
+	entries := []VocabularyEntry{}
+	err := dbSelect(ctx, db, "vocabulary_browse", &entries, `SELECT term, doc, cnt FROM verses_vocabulary
+		WHERE col = 'folded' AND term LIKE ? ESCAPE '\' AND term > ?
+		ORDER BY term LIMIT ?`, escapeLike(prefix)+"%", after, limit)
+	return entries, err
+}
+
+func registerConcordanceRoutes(api huma.API, db *sqlx.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-concordance",
+		Method:      http.MethodGet,
+		Path:        "/api/concordance/{word}",
+		Summary:     "Obtener la concordancia de una palabra",
+		Description: "Devuelve cada aparición de una palabra, sin distinguir mayúsculas ni acentos, con su versículo, su posición en el versículo y las palabras que la rodean, agrupadas y contadas por libro en orden canónico. Las apariciones se devuelven por páginas de hasta limit versículos; para pedir la siguiente, pasa en after el id del último versículo recibido.",
+		Tags:        []string{"Concordance"},
+		Security:    requireScope(ScopeSearch),
+	}, func(ctx context.Context, input *ConcordanceRequest) (*SingleResponse[Concordance], error) {
+		normalized := words(searchAnalyzer.fold(input.Word))
+		if len(normalized) != 1 {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("expected a single word, got %q", input.Word))
+		}
+		word := normalized[0]
+		if !searchVocabulary.known(word) {
+			return nil, huma.Error404NotFound(fmt.Sprintf("word not found in the text: %s", word))
+		}
+		page := concordancePage{limit: input.Limit}
+		if input.After != "" {
+			err := dbGet(ctx, db, "concordance_after", &page.after, `SELECT ordinal FROM verses WHERE id = ?`, input.After)
+			if err == sql.ErrNoRows {
+				return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("after must be the id of a verse, got %q", input.After))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error while getting verse from DB: %v", err)
+			}
+		}
+		result, err := concordance(ctx, db, word, input.Book, input.Occurrences, page)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting verses from DB: %v", err)
+		}
+		return &SingleResponse[Concordance]{
+			Body: result,
+		}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-vocabulary",
+		Method:      http.MethodGet,
+		Path:        "/api/concordance",
+		Summary:     "Listar las palabras del texto",
+		Description: "Devuelve, en orden alfabético, las palabras del texto que empiezan por prefix, con el número de versículos donde aparece cada una y el total de apariciones.",
+		Tags:        []string{"Concordance"},
+		Security:    requireScope(ScopeSearch),
+	}, func(ctx context.Context, input *VocabularyRequest) (*ListResponse[VocabularyEntry], error) {
+		parts := words(searchAnalyzer.fold(input.Prefix))
+		if len(parts) > 1 {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("expected the beginning of a single word, got %q", input.Prefix))
+		}
+		prefix := strings.Join(parts, "")
+		entries, err := browseVocabulary(ctx, db, prefix, input.After, input.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting vocabulary from DB: %v", err)
+		}
+		return &ListResponse[VocabularyEntry]{
+			Body: entries,
+		}, nil
+	})
+}
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting the server reads at startup. Values are taken, in
+// increasing order of precedence, from the defaults below, an optional YAML or
+// TOML config file, environment variables and command line flags.
+type Config struct {
+	// Env is the deployment environment, e.g. LOCAL or PROD.
+	Env string `yaml:"env" toml:"env"`
+	// DBPath is the path to the SQLite Bible.db file.
+	DBPath string `yaml:"dbPath" toml:"dbPath"`
+	// Addr is the address the HTTP server listens on.
+	Addr string `yaml:"addr" toml:"addr"`
+	// GRPCAddr is the address the gRPC server listens on when the grpc
+	// feature is enabled.
+	GRPCAddr string `yaml:"grpcAddr" toml:"grpcAddr"`
+	// BasePath is the path prefix the API is published under behind the
+	// gateway, e.g. "dev" for https://host/dev/api/books.
+	BasePath string `yaml:"basePath" toml:"basePath"`
+	// PublicURL is the public scheme and host clients reach the API on.
+	PublicURL string `yaml:"publicUrl" toml:"publicUrl"`
+	// Features lists the optional endpoint groups to enable.
+	Features []string `yaml:"features" toml:"features"`
+	// Cache sizes the in-memory caches.
+	Cache CacheConfig `yaml:"cache" toml:"cache"`
+	// Timeouts bounds how long the server spends on a connection.
+	Timeouts TimeoutConfig `yaml:"timeouts" toml:"timeouts"`
+	// Log controls the format and verbosity of the server logs.
+	Log LogConfig `yaml:"log" toml:"log"`
+	// Tracing controls OpenTelemetry trace export.
+	Tracing TracingConfig `yaml:"tracing" toml:"tracing"`
+	// Auth controls access for requests without an API key.
+	Auth AuthConfig `yaml:"auth" toml:"auth"`
+	// RateLimit sets the per client request budgets.
+	RateLimit RateLimitConfig `yaml:"rateLimit" toml:"rateLimit"`
+	// CORS controls which browser origins may call the API.
+	CORS CORSConfig `yaml:"cors" toml:"cors"`
+	// Compression controls how responses are compressed.
+	Compression CompressionConfig `yaml:"compression" toml:"compression"`
+	// Search controls how verse text is analyzed for searching.
+	Search SearchConfig `yaml:"search" toml:"search"`
+}
+
+type CacheConfig struct {
+	// MaxEntries is the maximum number of responses kept by each cache.
+	MaxEntries int `yaml:"maxEntries" toml:"maxEntries"`
+}
+
+type TimeoutConfig struct {
+	Read     time.Duration `yaml:"read" toml:"read"`
+	Write    time.Duration `yaml:"write" toml:"write"`
+	Idle     time.Duration `yaml:"idle" toml:"idle"`
+	Shutdown time.Duration `yaml:"shutdown" toml:"shutdown"`
+}
+
+type LogConfig struct {
+	// Format is "text" or "json".
+	Format string `yaml:"format" toml:"format"`
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string `yaml:"level" toml:"level"`
+	// SlowQuery is the duration above which a SQL query is logged as slow.
+	// Zero disables the slow query log.
+	SlowQuery time.Duration `yaml:"slowQuery" toml:"slowQuery"`
+}
+
+type TracingConfig struct {
+	// Exporter is "none" to disable tracing or "otlp" to send spans to the
+	// collector set by the standard OTEL_EXPORTER_OTLP_* variables.
+	Exporter string `yaml:"exporter" toml:"exporter"`
+	// ServiceName identifies this server in traces.
+	ServiceName string `yaml:"serviceName" toml:"serviceName"`
+	// SampleRatio is the fraction of new traces to record, from 0 to 1.
+	// Traces started by a caller follow the caller's sampling decision.
+	SampleRatio float64 `yaml:"sampleRatio" toml:"sampleRatio"`
+}
+
+type AuthConfig struct {
+	// AnonymousScopes are the scopes granted to requests without an API key.
+	// Leave empty to require a key for every operation that is not public.
+	AnonymousScopes []string `yaml:"anonymousScopes" toml:"anonymousScopes"`
+}
+
+type RateLimitConfig struct {
+	// Read, Search and Export are the limits for each route class per client
+	// IP. A zero rate disables limiting for the class.
+	Read   RateLimit `yaml:"read" toml:"read"`
+	Search RateLimit `yaml:"search" toml:"search"`
+	Export RateLimit `yaml:"export" toml:"export"`
+	// KeyFactor multiplies the limits for requests with a valid API key.
+	KeyFactor float64 `yaml:"keyFactor" toml:"keyFactor"`
+	// TrustedProxies are the CIDRs whose X-Forwarded-For header is used to
+	// find the client IP.
+	TrustedProxies []string `yaml:"trustedProxies" toml:"trustedProxies"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the API: exact
+	// origins, patterns like https://*.example.com, or "*" for any.
+	AllowedOrigins []string `yaml:"allowedOrigins" toml:"allowedOrigins"`
+	// AllowedMethods are the methods allowed in preflight requests.
+	AllowedMethods []string `yaml:"allowedMethods" toml:"allowedMethods"`
+	// AllowedHeaders are the request headers allowed in preflight requests,
+	// or "*" to allow whatever the browser asks for.
+	AllowedHeaders []string `yaml:"allowedHeaders" toml:"allowedHeaders"`
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders []string `yaml:"exposedHeaders" toml:"exposedHeaders"`
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration `yaml:"maxAge" toml:"maxAge"`
+	// AllowCredentials lets browsers send cookies and HTTP authentication.
+	AllowCredentials bool `yaml:"allowCredentials" toml:"allowCredentials"`
+}
+
+type CompressionConfig struct {
+	// MinSize is the smallest response body, in bytes, that is compressed.
+	MinSize int `yaml:"minSize" toml:"minSize"`
+	// Encodings are the content codings offered, in order of preference.
+	Encodings []string `yaml:"encodings" toml:"encodings"`
+}
+
+type SearchConfig struct {
+	// PreserveEnye keeps "ñ" distinct from "n" when accents are ignored, so
+	// "año" does not match "ano".
+	PreserveEnye bool `yaml:"preserveEnye" toml:"preserveEnye"`
+	// Stopwords drops common Spanish words such as "de" or "la" from stemmed
+	// searches and the stemmed index.
+	Stopwords bool `yaml:"stopwords" toml:"stopwords"`
+	// RegexTimeout is how long a regex or wildcard search may scan the text
+	// before it returns the verses found so far.
+	RegexTimeout time.Duration `yaml:"regexTimeout" toml:"regexTimeout"`
+}
+
+// Optional endpoint groups that can be turned on with Config.Features.
+const (
+	FeatureAdmin       = "admin"
+	FeatureMetrics     = "metrics"
+	FeatureRateLimit   = "ratelimit"
+	FeatureCORS        = "cors"
+	FeatureCompression = "compression"
+	FeatureGraphQL     = "graphql"
+	FeatureGRPC        = "grpc"
+	FeatureMCP         = "mcp"
+)
+
+var knownFeatures = []string{FeatureAdmin, FeatureMetrics, FeatureRateLimit, FeatureCORS, FeatureCompression, FeatureGraphQL, FeatureGRPC, FeatureMCP}
+
+func defaultConfig() Config {
+	return Config{
+		Env:      "LOCAL",
+		DBPath:   "Bible.db",
+		Addr:     ":8888",
+		GRPCAddr: ":9090",
+		BasePath: "dev",
+		Features: []string{FeatureAdmin, FeatureMetrics, FeatureRateLimit, FeatureCORS, FeatureCompression, FeatureGraphQL},
+		Cache: CacheConfig{
+			MaxEntries: 1024,
+		},
+		Timeouts: TimeoutConfig{
+			Read:     10 * time.Second,
+			Write:    30 * time.Second,
+			Idle:     120 * time.Second,
+			Shutdown: 20 * time.Second,
+		},
+		Log: LogConfig{
+			Format:    "text",
+			Level:     "info",
+			SlowQuery: 200 * time.Millisecond,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "spanish-bible-api",
+			SampleRatio: 1,
+		},
+		Auth: AuthConfig{
+			AnonymousScopes: []string{ScopeRead, ScopeSearch},
+		},
+		RateLimit: RateLimitConfig{
+			Read:      RateLimit{PerMinute: 120, Burst: 60},
+			Search:    RateLimit{PerMinute: 20, Burst: 10},
+			Export:    RateLimit{PerMinute: 30, Burst: 10},
+			KeyFactor: 5,
+			TrustedProxies: []string{
+				"127.0.0.0/8", "::1/128",
+				"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+			},
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodOptions},
+			AllowedHeaders: []string{"Accept", "Content-Type", apiKeyHeader, requestIdHeader, "traceparent", "tracestate"},
+			ExposedHeaders: []string{requestIdHeader, "Link", "Retry-After", "Search-Suggestions", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+			MaxAge:         10 * time.Minute,
+		},
+		Search: SearchConfig{
+			PreserveEnye: true,
+			Stopwords:    true,
+			RegexTimeout: 2 * time.Second,
+		},
+		Compression: CompressionConfig{
+			MinSize:   1024,
+			Encodings: slices.Clone(knownEncodings),
+		},
+	}
+}
+
+// IsProduction reports whether the server runs in the production environment.
+func (c Config) IsProduction() bool {
+	return c.Env == "PROD" || c.Env == "PRODUCTION"
+}
+
+// ServerURL is the public URL of the API including the base path, as shown in
+// the OpenAPI servers list.
+func (c Config) ServerURL() string {
+	serverUrl := strings.TrimSuffix(c.PublicURL, "/")
+	if basePath := strings.Trim(c.BasePath, "/"); basePath != "" {
+		serverUrl += "/" + basePath
+	}
+	return serverUrl
+}
+
+// FeatureEnabled reports whether the named optional feature is turned on.
+func (c Config) FeatureEnabled(feature string) bool {
+	return slices.Contains(c.Features, feature)
+}
+
+// listValue is a flag.Value for comma separated lists such as FEATURES.
+type listValue struct {
+	list *[]string
+}
+
+func (v listValue) String() string {
+	if v.list == nil {
+		return ""
+	}
+	return strings.Join(*v.list, ",")
+}
+
+func (v listValue) Set(s string) error {
+	*v.list = []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v.list = append(*v.list, item)
+		}
+	}
+	return nil
+}
+
+// portValue is a flag.Value that sets the listen address from a bare port,
+// for platforms like Elastic Beanstalk that only provide PORT.
+type portValue struct {
+	addr *string
+}
+
+func (v portValue) String() string {
+	if v.addr == nil {
+		return ""
+	}
+	_, port, _ := net.SplitHostPort(*v.addr)
+	return port
+}
+
+func (v portValue) Set(s string) error {
+	*v.addr = ":" + s
+	return nil
+}
+
+// configEnv maps each flag to the environment variable that can also set it.
+// Flags are listed in the order environment variables are applied, so PORT
+// comes before LISTEN_ADDR and the latter wins when both are present.
+var configEnv = []struct {
+	flag string
+	env  string
+}{
+	{"env", "GO_ENV"},
+	{"db", "DB_PATH"},
+	{"port", "PORT"},
+	{"addr", "LISTEN_ADDR"},
+	{"grpc-addr", "GRPC_ADDR"},
+	{"base-path", "BASE_PATH"},
+	{"public-url", "HOST_URL"},
+	{"features", "FEATURES"},
+	{"cache-max-entries", "CACHE_MAX_ENTRIES"},
+	{"read-timeout", "READ_TIMEOUT"},
+	{"write-timeout", "WRITE_TIMEOUT"},
+	{"idle-timeout", "IDLE_TIMEOUT"},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT"},
+	{"log-format", "LOG_FORMAT"},
+	{"log-level", "LOG_LEVEL"},
+	{"slow-query", "SLOW_QUERY_THRESHOLD"},
+	{"tracing-exporter", "OTEL_TRACES_EXPORTER"},
+	{"tracing-service-name", "OTEL_SERVICE_NAME"},
+	{"tracing-sample-ratio", "OTEL_TRACES_SAMPLER_ARG"},
+	{"anonymous-scopes", "ANONYMOUS_SCOPES"},
+	{"rate-limit-read", "RATE_LIMIT_READ"},
+	{"rate-limit-search", "RATE_LIMIT_SEARCH"},
+	{"rate-limit-export", "RATE_LIMIT_EXPORT"},
+	{"rate-limit-key-factor", "RATE_LIMIT_KEY_FACTOR"},
+	{"trusted-proxies", "TRUSTED_PROXIES"},
+	{"cors-origins", "CORS_ALLOWED_ORIGINS"},
+	{"cors-methods", "CORS_ALLOWED_METHODS"},
+	{"cors-headers", "CORS_ALLOWED_HEADERS"},
+	{"cors-exposed-headers", "CORS_EXPOSED_HEADERS"},
+	{"cors-max-age", "CORS_MAX_AGE"},
+	{"cors-credentials", "CORS_ALLOW_CREDENTIALS"},
+	{"compression-min-size", "COMPRESSION_MIN_SIZE"},
+	{"compression-encodings", "COMPRESSION_ENCODINGS"},
+	{"search-preserve-enye", "SEARCH_PRESERVE_ENYE"},
+	{"search-stopwords", "SEARCH_STOPWORDS"},
+	{"search-regex-timeout", "SEARCH_REGEX_TIMEOUT"},
+}
+
+// newFlagSet binds a flag for every setting to c, using c's current values as
+// the defaults.
+func newFlagSet(c *Config, configFile *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.StringVar(configFile, "config", *configFile, "path to a YAML or TOML config file (env CONFIG_FILE)")
+	fs.StringVar(&c.Env, "env", c.Env, "deployment environment, e.g. LOCAL or PROD (env GO_ENV)")
+	fs.StringVar(&c.DBPath, "db", c.DBPath, "path to the Bible.db SQLite file (env DB_PATH)")
+	fs.Var(portValue{&c.Addr}, "port", "port to listen on, shorthand for -addr :PORT (env PORT)")
+	fs.StringVar(&c.Addr, "addr", c.Addr, "address to listen on (env LISTEN_ADDR)")
+	fs.StringVar(&c.GRPCAddr, "grpc-addr", c.GRPCAddr, "address the gRPC server listens on with the grpc feature (env GRPC_ADDR)")
+	fs.StringVar(&c.BasePath, "base-path", c.BasePath, "path prefix the API is published under (env BASE_PATH)")
+	fs.StringVar(&c.PublicURL, "public-url", c.PublicURL, "public URL of the API, required in production (env HOST_URL)")
+	fs.Var(listValue{&c.Features}, "features", "comma separated optional features: "+strings.Join(knownFeatures, ", ")+" (env FEATURES)")
+	fs.IntVar(&c.Cache.MaxEntries, "cache-max-entries", c.Cache.MaxEntries, "maximum entries per in-memory cache (env CACHE_MAX_ENTRIES)")
+	fs.DurationVar(&c.Timeouts.Read, "read-timeout", c.Timeouts.Read, "maximum time to read a request (env READ_TIMEOUT)")
+	fs.DurationVar(&c.Timeouts.Write, "write-timeout", c.Timeouts.Write, "maximum time to write a response (env WRITE_TIMEOUT)")
+	fs.DurationVar(&c.Timeouts.Idle, "idle-timeout", c.Timeouts.Idle, "maximum time to keep an idle connection open (env IDLE_TIMEOUT)")
+	fs.DurationVar(&c.Timeouts.Shutdown, "shutdown-timeout", c.Timeouts.Shutdown, "maximum time to drain connections on shutdown (env SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&c.Log.Format, "log-format", c.Log.Format, "log output format, text or json (env LOG_FORMAT)")
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "minimum log level: debug, info, warn or error (env LOG_LEVEL)")
+	fs.DurationVar(&c.Log.SlowQuery, "slow-query", c.Log.SlowQuery, "log SQL queries slower than this, 0 to disable (env SLOW_QUERY_THRESHOLD)")
+	fs.StringVar(&c.Tracing.Exporter, "tracing-exporter", c.Tracing.Exporter, "trace exporter, none or otlp (env OTEL_TRACES_EXPORTER)")
+	fs.StringVar(&c.Tracing.ServiceName, "tracing-service-name", c.Tracing.ServiceName, "service name reported in traces (env OTEL_SERVICE_NAME)")
+	fs.Float64Var(&c.Tracing.SampleRatio, "tracing-sample-ratio", c.Tracing.SampleRatio, "fraction of new traces to record, 0 to 1 (env OTEL_TRACES_SAMPLER_ARG)")
+	fs.Var(listValue{&c.Auth.AnonymousScopes}, "anonymous-scopes", "comma separated scopes allowed without an API key, empty to require keys (env ANONYMOUS_SCOPES)")
+	fs.Var(rateLimitValue{&c.RateLimit.Read}, "rate-limit-read", "single verse, chapter and book requests per minute per client, as PER_MINUTE:BURST (env RATE_LIMIT_READ)")
+	fs.Var(rateLimitValue{&c.RateLimit.Search}, "rate-limit-search", "search requests per minute per client, as PER_MINUTE:BURST (env RATE_LIMIT_SEARCH)")
+	fs.Var(rateLimitValue{&c.RateLimit.Export}, "rate-limit-export", "verse range requests per minute per client, as PER_MINUTE:BURST (env RATE_LIMIT_EXPORT)")
+	fs.Float64Var(&c.RateLimit.KeyFactor, "rate-limit-key-factor", c.RateLimit.KeyFactor, "multiplier applied to the limits for requests with an API key (env RATE_LIMIT_KEY_FACTOR)")
+	fs.Var(listValue{&c.RateLimit.TrustedProxies}, "trusted-proxies", "comma separated CIDRs whose X-Forwarded-For header is trusted (env TRUSTED_PROXIES)")
+	fs.Var(listValue{&c.CORS.AllowedOrigins}, "cors-origins", "comma separated origins allowed by CORS, * for any (env CORS_ALLOWED_ORIGINS)")
+	fs.Var(listValue{&c.CORS.AllowedMethods}, "cors-methods", "comma separated methods allowed by CORS (env CORS_ALLOWED_METHODS)")
+	fs.Var(listValue{&c.CORS.AllowedHeaders}, "cors-headers", "comma separated request headers allowed by CORS, * for any (env CORS_ALLOWED_HEADERS)")
+	fs.Var(listValue{&c.CORS.ExposedHeaders}, "cors-exposed-headers", "comma separated response headers exposed to scripts (env CORS_EXPOSED_HEADERS)")
+	fs.DurationVar(&c.CORS.MaxAge, "cors-max-age", c.CORS.MaxAge, "how long browsers may cache preflight responses (env CORS_MAX_AGE)")
+	fs.BoolVar(&c.CORS.AllowCredentials, "cors-credentials", c.CORS.AllowCredentials, "allow credentialed CORS requests (env CORS_ALLOW_CREDENTIALS)")
+	fs.IntVar(&c.Compression.MinSize, "compression-min-size", c.Compression.MinSize, "smallest response body in bytes that is compressed (env COMPRESSION_MIN_SIZE)")
+	fs.Var(listValue{&c.Compression.Encodings}, "compression-encodings", "comma separated encodings offered, most preferred first: "+strings.Join(knownEncodings, ", ")+" (env COMPRESSION_ENCODINGS)")
+	fs.BoolVar(&c.Search.PreserveEnye, "search-preserve-enye", c.Search.PreserveEnye, "keep ñ distinct from n in accent-insensitive and stemmed searches (env SEARCH_PRESERVE_ENYE)")
+	fs.BoolVar(&c.Search.Stopwords, "search-stopwords", c.Search.Stopwords, "ignore Spanish stopwords in stemmed searches (env SEARCH_STOPWORDS)")
+	fs.DurationVar(&c.Search.RegexTimeout, "search-regex-timeout", c.Search.RegexTimeout, "maximum time a regex or wildcard search scans the text (env SEARCH_REGEX_TIMEOUT)")
+	return fs
+}
+
+// loadConfig builds the configuration from defaults, the config file, the
+// environment and args, in that order, and validates the result. It returns
+// the remaining positional arguments, e.g. the command to run.
+func loadConfig(args []string) (Config, []string, error) {
+	// Parse once only to find the config file; the file must be applied
+	// before the environment and flags so they can override it.
+	c := defaultConfig()
+	configFile := os.Getenv("CONFIG_FILE")
+	if err := newFlagSet(&c, &configFile).Parse(args); err != nil {
+		return c, nil, err
+	}
+
+	c = defaultConfig()
+	if configFile != "" {
+		if err := loadConfigFile(&c, configFile); err != nil {
+			return c, nil, err
+		}
+	}
+	fs := newFlagSet(&c, &configFile)
+	for _, v := range configEnv {
+		if value, ok := os.LookupEnv(v.env); ok && value != "" {
+			if err := fs.Set(v.flag, value); err != nil {
+				return c, nil, fmt.Errorf("invalid value %q for %s: %v", value, v.env, err)
+			}
+		}
+	}
+	fs = newFlagSet(&c, &configFile)
+	if err := fs.Parse(args); err != nil {
+		return c, nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return c, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+func loadConfigFile(c *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error while reading config file: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), c)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", meta.Undecoded())
+		}
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error while parsing config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate checks the configuration and returns every problem found, so they
+// can all be fixed in one go.
+func (c Config) Validate() error {
+	var errs []error
+	if c.DBPath == "" {
+		errs = append(errs, errors.New("db: path to Bible.db is required"))
+	} else if info, err := os.Stat(c.DBPath); err != nil {
+		errs = append(errs, fmt.Errorf("db: cannot open %s: %v", c.DBPath, err))
+	} else if info.IsDir() {
+		errs = append(errs, fmt.Errorf("db: %s is a directory, not a SQLite file", c.DBPath))
+	}
+	if _, port, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr: %q is not a valid listen address, expected host:port or :port", c.Addr))
+	} else if port == "" {
+		errs = append(errs, fmt.Errorf("addr: %q has no port", c.Addr))
+	}
+	if c.FeatureEnabled(FeatureGRPC) {
+		if _, port, err := net.SplitHostPort(c.GRPCAddr); err != nil || port == "" {
+			errs = append(errs, fmt.Errorf("grpc-addr: %q is not a valid listen address, expected host:port or :port", c.GRPCAddr))
+		} else if c.GRPCAddr == c.Addr {
+			errs = append(errs, fmt.Errorf("grpc-addr: %q is already used by the HTTP server", c.GRPCAddr))
+		}
+	}
+	if strings.Contains(strings.Trim(c.BasePath, "/"), "//") {
+		errs = append(errs, fmt.Errorf("base-path: %q contains empty segments", c.BasePath))
+	}
+	if c.PublicURL != "" {
+		u, err := url.Parse(c.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("public-url: %q must be an absolute http(s) URL such as https://api.example.com", c.PublicURL))
+		}
+	} else if c.IsProduction() {
+		errs = append(errs, errors.New("public-url: HOST_URL is required in production"))
+	}
+	for _, feature := range c.Features {
+		if !slices.Contains(knownFeatures, feature) {
+			errs = append(errs, fmt.Errorf("features: unknown feature %q, expected one of %s", feature, strings.Join(knownFeatures, ", ")))
+		}
+	}
+	if c.Cache.MaxEntries < 0 {
+		errs = append(errs, errors.New("cache-max-entries: must not be negative"))
+	}
+	timeouts := []struct {
+		name    string
+		timeout time.Duration
+	}{
+		{"read-timeout", c.Timeouts.Read},
+		{"write-timeout", c.Timeouts.Write},
+		{"idle-timeout", c.Timeouts.Idle},
+		{"shutdown-timeout", c.Timeouts.Shutdown},
+		{"search-regex-timeout", c.Search.RegexTimeout},
+	}
+	for _, t := range timeouts {
+		if t.timeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be greater than zero", t.name))
+		}
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("log-format: %q must be text or json", c.Log.Format))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log-level: %q must be debug, info, warn or error", c.Log.Level))
+	}
+	if c.Log.SlowQuery < 0 {
+		errs = append(errs, errors.New("slow-query: must not be negative"))
+	}
+	if c.Tracing.Exporter != "none" && c.Tracing.Exporter != "otlp" {
+		errs = append(errs, fmt.Errorf("tracing-exporter: %q must be none or otlp", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing-sample-ratio: %v must be between 0 and 1", c.Tracing.SampleRatio))
+	}
+	for _, scope := range c.Auth.AnonymousScopes {
+		if scope == ScopeAdmin {
+			errs = append(errs, errors.New("anonymous-scopes: the admin scope cannot be granted to anonymous requests"))
+		} else if !slices.Contains(knownScopes, scope) {
+			errs = append(errs, fmt.Errorf("anonymous-scopes: unknown scope %q, expected one of %s", scope, strings.Join(knownScopes, ", ")))
+		}
+	}
+	for _, limit := range []struct {
+		name  string
+		limit RateLimit
+	}{
+		{"rate-limit-read", c.RateLimit.Read},
+		{"rate-limit-search", c.RateLimit.Search},
+		{"rate-limit-export", c.RateLimit.Export},
+	} {
+		if limit.limit.PerMinute < 0 || limit.limit.Burst < 0 {
+			errs = append(errs, fmt.Errorf("%s: %s must not be negative", limit.name, limit.limit))
+		} else if limit.limit.PerMinute > 0 && limit.limit.Burst == 0 {
+			errs = append(errs, fmt.Errorf("%s: %s needs a burst of at least 1", limit.name, limit.limit))
+		}
+	}
+	if c.RateLimit.KeyFactor <= 0 {
+		errs = append(errs, errors.New("rate-limit-key-factor: must be greater than zero"))
+	}
+	for _, cidr := range c.RateLimit.TrustedProxies {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			errs = append(errs, fmt.Errorf("trusted-proxies: %q is not a CIDR such as 10.0.0.0/8", cidr))
+		}
+	}
+	if c.FeatureEnabled(FeatureCORS) && len(c.CORS.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("cors-origins: at least one origin is required when the cors feature is enabled"))
+	}
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		errs = append(errs, errors.New("cors-credentials: browsers reject credentials with a * origin; list the allowed origins instead"))
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			errs = append(errs, fmt.Errorf("cors-origins: %q must be * or start with http:// or https://", origin))
+		}
+	}
+	if c.Compression.MinSize < 0 {
+		errs = append(errs, errors.New("compression-min-size: must not be negative"))
+	}
+	for _, encoding := range c.Compression.Encodings {
+		if !slices.Contains(knownEncodings, encoding) {
+			errs = append(errs, fmt.Errorf("compression-encodings: unknown encoding %q, expected one of %s", encoding, strings.Join(knownEncodings, ", ")))
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, errors.New("cors-max-age: must not be negative"))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %v", strings.ReplaceAll(errors.Join(errs...).Error(), "\n", "\n  "))
+	}
+	return nil
+}
+
+// String renders the configuration for the startup log. The configuration
+// holds no secrets; the OTLP exporter reads its credentials straight from
+// the OTEL_EXPORTER_OTLP_* variables.
+func (c Config) String() string {
+	var b strings.Builder
+	writeConfigFields(&b, "", reflect.ValueOf(c))
+	return strings.TrimSuffix(b.String(), " ")
+}
+
+func writeConfigFields(b *strings.Builder, prefix string, v reflect.Value) {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		value := v.Field(i)
+		if value.Kind() == reflect.Struct {
+			writeConfigFields(b, prefix+name+".", value)
+			continue
+		}
+		fmt.Fprintf(b, "%s%s=%v ", prefix, name, value.Interface())
+	}
+}
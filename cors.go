@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// cors answers CORS preflight requests and adds the CORS response headers to
+// every request from an allowed origin, so browsers can call the API
+// whatever proxy, if any, sits in front of it.
+type cors struct {
+	cfg     CORSConfig
+	methods string
+	headers string
+	exposed string
+	maxAge  string
+}
+
+func newCORS(cfg CORSConfig) *cors {
+	return &cors{
+		cfg:     cfg,
+		methods: strings.Join(cfg.AllowedMethods, ", "),
+		headers: strings.Join(cfg.AllowedHeaders, ", "),
+		exposed: strings.Join(cfg.ExposedHeaders, ", "),
+		maxAge:  strconv.Itoa(int(cfg.MaxAge.Seconds())),
+	}
+}
+
+// originAllowed reports whether origin matches one of the allowed origins.
+// An entry of "*" allows every origin, and an entry like
+// "https://*.example.com" allows any subdomain of example.com.
+func (c *cors) originAllowed(origin string) bool {
+	for _, allowed := range c.cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+			strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin sets Access-Control-Allow-Origin for an allowed origin. The
+// origin is echoed back, rather than using "*", whenever the answer depends
+// on it, so caches must vary on it.
+func (c *cors) allowOrigin(h http.Header, origin string) {
+	if slices.Contains(c.cfg.AllowedOrigins, "*") && !c.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Add("Vary", "Origin")
+	if c.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Middleware is chi middleware applying the CORS policy.
+func (c *cors) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !c.originAllowed(origin) {
+			if preflight {
+				w.Header().Add("Vary", "Origin")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		c.allowOrigin(h, origin)
+		if !preflight {
+			if c.exposed != "" {
+				h.Set("Access-Control-Expose-Headers", c.exposed)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		method := r.Header.Get("Access-Control-Request-Method")
+		if !slices.Contains(c.cfg.AllowedMethods, method) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Allow-Methods", c.methods)
+		if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			if slices.Contains(c.cfg.AllowedHeaders, "*") {
+				h.Set("Access-Control-Allow-Headers", requested)
+			} else {
+				h.Set("Access-Control-Allow-Headers", c.headers)
+			}
+		}
+		if c.cfg.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", c.maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+// Cross-references link a verse to related passages, imported from the
+// public-domain OpenBible.info dataset, itself based on the Treasury of
+// Scripture Knowledge. Each one has the votes OpenBible's users gave it,
+// which rank how relevant it is.
+
+// CrossReference is a passage related to a verse.
+type CrossReference struct {
+	Passage string  `json:"passage" doc:"Referencia OSIS del pasaje relacionado, ej: spa-RVR1960:Prov.8.22-Prov.8.30"`
+	Votes   int     `json:"votes" doc:"Votos de relevancia del conjunto de datos de OpenBible.info; cuantos más votos, más relevante"`
+	Verses  []Verse `json:"verses" doc:"Los versículos del pasaje"`
+}
+
+type CrossReferencesRequest struct {
+	VerseFieldsRequest
+	VerseID  string `path:"verseId" doc:"Id del versículo, ej: spa-RVR1960:John.3.16"`
+	MinVotes int    `query:"minVotes" default:"0" doc:"Devolver solo las referencias con al menos estos votos; algunas tienen votos negativos"`
+	Limit    int    `query:"limit" minimum:"1" maximum:"200" default:"50" doc:"Máximo de referencias a devolver, las más votadas"`
+}
+
+// crossRefRow is a row of the crossrefs table.
+type crossRefRow struct {
+	FromVerseID  string `db:"fromVerseId"`
+	BookID       string `db:"toBookId"`
+	StartChapter int    `db:"toStartChapter"`
+	StartVerse   int    `db:"toStartVerse"`
+	EndChapter   int    `db:"toEndChapter"`
+	EndVerse     int    `db:"toEndVerse"`
+	Votes        int    `db:"votes"`
+}
+
+func (r crossRefRow) passage() passage {
+	return passage{BookID: r.BookID, StartChapter: r.StartChapter, StartVerse: r.StartVerse, EndChapter: r.EndChapter, EndVerse: r.EndVerse}
+}
+
+// crossReferences returns, for each of the verses, up to limit of its
+// cross-references with at least minVotes votes, the most voted first and
+// ties in canonical order, with the verses of each passage.
+func crossReferences(ctx context.Context, db *sqlx.DB, verseIds []string, minVotes, limit int, fields verseSelection) (map[string][]CrossReference, error) {
+	result := map[string][]CrossReference{}
+	if len(verseIds) == 0 {
+		return result, nil
+	}
+	query, args, err := sqlx.In(`SELECT fromVerseId, toBookId, toStartChapter, toStartVerse, toEndChapter, toEndVerse, votes FROM (
+			SELECT crossrefs.*, row_number() OVER (
+				PARTITION BY fromVerseId
+				ORDER BY votes DESC, books."order", toStartChapter, toStartVerse, toEndChapter, toEndVerse
+			) AS rank
+			FROM crossrefs JOIN books ON books.id = crossrefs.toBookId
+			WHERE fromVerseId IN (?) AND votes >= ?
+		) WHERE rank <= ? ORDER BY rank`, verseIds, minVotes, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows := []crossRefRow{}
+	if err := dbSelect(ctx, db, "crossrefs", &rows, query, args...); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	refs := []string{}
+	for _, row := range rows {
+		refs = append(refs, row.passage().String())
+	}
+	passages, err := lookupBatch(ctx, db, refs, fields)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		ref := row.passage().String()
+		// Passages missing from this translation's versification are left
+		// out.
+		if item := passages[ref]; len(item.Verses) > 0 {
+			result[row.FromVerseID] = append(result[row.FromVerseID], CrossReference{Passage: ref, Votes: row.Votes, Verses: item.Verses})
+		}
+	}
+	return result, nil
+}
+
+// mutableOperation is the Operation.Metadata key of operations whose
+// responses can change, so markImmutable must leave them alone. Its value is
+// true, or the name of a query parameter that makes a request mutable when it
+// is set to anything but 0. Cross-references change when they are imported
+// again.
+const mutableOperation = "mutable"
+
+// ChapterVerse is a verse of a chapter with, if asked for, its most voted
+// cross-references. They are kept out of Verse so that the verses of a
+// CrossReference do not nest further cross-references.
+type ChapterVerse struct {
+	Verse
+	CrossReferences []CrossReference `json:"crossReferences,omitempty" doc:"Las referencias cruzadas más votadas del versículo, si se pidieron con crossrefs"`
+}
+
+// withCrossReferences returns the verses with up to limit of their most
+// voted cross-references each, or none if limit is 0.
+func withCrossReferences(ctx context.Context, db *sqlx.DB, verses []Verse, limit int, fields verseSelection) ([]ChapterVerse, error) {
+	result := make([]ChapterVerse, len(verses))
+	ids := []string{}
+	for i, v := range verses {
+		result[i].Verse = v
+		ids = append(ids, v.ID)
+	}
+	if limit == 0 {
+		return result, nil
+	}
+	refs, err := crossReferences(ctx, db, ids, 0, limit, fields)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result {
+		result[i].CrossReferences = refs[result[i].ID]
+	}
+	return result, nil
+}
+
+// importCrossRefs replaces the cross-references with those read from r, in
+// the OpenBible.info format: one cross-reference per line with the OSIS
+// reference of the verse, that of the related verse or range and the votes,
+// separated by tabs, like "Gen.1.1	Prov.8.22-Prov.8.30	59". The votes are
+// optional, and a header line and lines starting with # are ignored.
+// Cross-references with fewer than minVotes votes, or whose verses are not
+// in this translation, are skipped and reported to skip. It returns the
+// number of cross-references imported.
+func importCrossRefs(ctx context.Context, db *sqlx.DB, r io.Reader, minVotes int, skip func(line int, err error)) (int, error) {
+	books, err := loadBookIndex(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	ids := []string{}
+	if err := dbSelect(ctx, db, "verse_ids", &ids, `SELECT id FROM verses`); err != nil {
+		return 0, fmt.Errorf("error while getting verses from DB: %v", err)
+	}
+	exists := map[string]bool{}
+	for _, id := range ids {
+		exists[id] = true
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM crossrefs`); err != nil {
+		return 0, err
+	}
+	insert, err := tx.PreparexContext(ctx, `INSERT OR REPLACE INTO crossrefs (fromVerseId, toBookId, toStartChapter, toStartVerse, toEndChapter, toEndVerse, votes) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	imported := 0
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, "From Verse") {
+			continue
+		}
+		columns := strings.Split(text, "\t")
+		if len(columns) < 2 {
+			skip(line, fmt.Errorf("expected a verse and a related passage separated by a tab"))
+			continue
+		}
+		from, err := parseReference(columns[0], books)
+		if err != nil {
+			skip(line, err)
+			continue
+		}
+		to, err := parseReference(columns[1], books)
+		if err != nil {
+			skip(line, err)
+			continue
+		}
+		votes := 0
+		if len(columns) > 2 && strings.TrimSpace(columns[2]) != "" {
+			if votes, err = strconv.Atoi(strings.TrimSpace(columns[2])); err != nil {
+				skip(line, fmt.Errorf("invalid votes %q", columns[2]))
+				continue
+			}
+		}
+		if votes < minVotes {
+			continue
+		}
+		if from.StartVerse == 0 || from.StartChapter != from.EndChapter || from.StartVerse != from.EndVerse {
+			skip(line, fmt.Errorf("%s is not a single verse", from))
+			continue
+		}
+		fromId := from.String()
+		if !exists[fromId] {
+			skip(line, fmt.Errorf("verse not found: %s", fromId))
+			continue
+		}
+		if first := fmt.Sprintf("%s.%d.%d", to.BookID, to.StartChapter, max(to.StartVerse, 1)); !exists[first] {
+			skip(line, fmt.Errorf("verse not found: %s", first))
+			continue
+		}
+		if _, err := insert.ExecContext(ctx, fromId, to.BookID, to.StartChapter, to.StartVerse, to.EndChapter, to.EndVerse, votes); err != nil {
+			return 0, fmt.Errorf("error while importing line %d: %v", line, err)
+		}
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("error while reading cross-references: %v", err)
+	}
+	return imported, tx.Commit()
+}
+
+func runCrossRefs(db *sqlx.DB, args []string) int {
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "usage: crossrefs import [-min-votes N] FILE")
+		return 2
+	}
+	if len(args) == 0 || args[0] != "import" {
+		return usage()
+	}
+	fs := flag.NewFlagSet("crossrefs import", flag.ContinueOnError)
+	minVotes := fs.Int("min-votes", 0, "skip cross-references with fewer votes; all are imported if omitted")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	threshold := math.MinInt
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "min-votes" {
+			threshold = *minVotes
+		}
+	})
+	if fs.NArg() != 1 {
+		return usage()
+	}
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+	skipped := 0
+	imported, err := importCrossRefs(context.Background(), db, file, threshold, func(line int, err error) {
+		skipped++
+		fmt.Fprintf(os.Stderr, "line %d skipped: %v\n", line, err)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Imported %d cross-references, skipped %d\n", imported, skipped)
+	return 0
+}
+
+func registerCrossRefRoutes(api huma.API, db *sqlx.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-verse-crossrefs",
+		Method:      http.MethodGet,
+		Path:        "/api/verses/{verseId}/crossrefs",
+		Summary:     "Obtener las referencias cruzadas de un versículo",
+		Description: "Devuelve los pasajes relacionados con un versículo, de la Treasury of Scripture Knowledge según OpenBible.info, con sus versículos y sus votos de relevancia, de los más a los menos votados.",
+		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeRead),
+		Metadata:    map[string]any{mutableOperation: true},
+	}, func(ctx context.Context, input *CrossReferencesRequest) (*ListResponse[CrossReference], error) {
+		if _, err := getVerse(ctx, db, input.VerseID, verseSelection{"id"}); err != nil {
+			if err != sql.ErrNoRows {
+				return nil, fmt.Errorf("error while getting verse from DB: %v", err)
+			}
+			return nil, huma.Error404NotFound(fmt.Sprintf("verse not found: %s", input.VerseID))
+		}
+		refs, err := crossReferences(ctx, db, []string{input.VerseID}, input.MinVotes, input.Limit, input.selection())
+		if err != nil {
+			return nil, fmt.Errorf("error while getting cross-references from DB: %v", err)
+		}
+		result := refs[input.VerseID]
+		if result == nil {
+			result = []CrossReference{}
+		}
+		return &ListResponse[CrossReference]{
+			Body: result,
+		}, nil
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dbSelect runs a query that returns many rows into dest, like
+// sqlx.SelectContext, recording how long it took under the given query name,
+// logging it if it was slow and tracing it as a child span of ctx.
+func dbSelect(ctx context.Context, q sqlx.QueryerContext, name string, dest any, query string, args ...any) error {
+	ctx, span := startQuerySpan(ctx, name, query)
+	start := time.Now()
+	err := sqlx.SelectContext(ctx, q, dest, query, args...)
+	observeQuery(ctx, name, query, start, err)
+	endQuerySpan(span, resultRows(dest), err)
+	return err
+}
+
+// dbGet runs a query that returns a single row into dest, like
+// sqlx.GetContext, recording how long it took under the given query name,
+// logging it if it was slow and tracing it as a child span of ctx.
+func dbGet(ctx context.Context, q sqlx.QueryerContext, name string, dest any, query string, args ...any) error {
+	ctx, span := startQuerySpan(ctx, name, query)
+	start := time.Now()
+	err := sqlx.GetContext(ctx, q, dest, query, args...)
+	observeQuery(ctx, name, query, start, err)
+	endQuerySpan(span, resultRows(dest), err)
+	return err
+}
+
+// dbEach runs a query that returns many rows and calls fn with each one, as
+// it is read, so results can be streamed to the client instead of collected
+// first. It stops at the first error fn returns, and returns it. The time
+// recorded for the query includes the time spent in fn.
+func dbEach[T any](ctx context.Context, q sqlx.QueryerContext, name string, fn func(T) error, query string, args ...any) error {
+	ctx, span := startQuerySpan(ctx, name, query)
+	start := time.Now()
+	count := 0
+	var fnErr error
+	err := func() error {
+		rows, err := q.QueryxContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var row T
+			if err := rows.StructScan(&row); err != nil {
+				return err
+			}
+			count++
+			if fnErr = fn(row); fnErr != nil {
+				return nil
+			}
+		}
+		return rows.Err()
+	}()
+	observeQuery(ctx, name, query, start, err)
+	endQuerySpan(span, count, err)
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// resultRows returns how many rows were read into the dest of dbSelect or
+// dbGet.
+func resultRows(dest any) int {
+	if v := reflect.Indirect(reflect.ValueOf(dest)); v.Kind() == reflect.Slice {
+		return v.Len()
+	}
+	return 1
+}
+
+func observeQuery(ctx context.Context, name, query string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	dbQueryDuration.WithLabelValues(name).Observe(elapsed.Seconds())
+	logSlowQuery(ctx, name, elapsed, query)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		dbQueryErrors.WithLabelValues(name).Inc()
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// testBook is a book of the test database with the text of its chapters.
+type testBook struct {
+	id, name, testament string
+	order               int
+	chapters            map[int][]string
+}
+
+var testBooks = []testBook{
+	{"spa-RVR1960:Gen", "Génesis", "OT", 1, map[int][]string{
+		1: {
+			"En el principio creó Dios los cielos y la tierra.",
+			"Y la tierra estaba desordenada y vacía, y las tinieblas estaban sobre la faz del abismo, y el Espíritu de Dios se movía sobre la faz de las aguas.",
+			"Y dijo Dios: Sea la luz; y fue la luz.",
+		},
+		2: {
+			"Fueron, pues, acabados los cielos y la tierra, y todo el ejército de ellos.",
+			"Y acabó Dios en el día séptimo la obra que hizo; y reposó el día séptimo de toda la obra que hizo.",
+		},
+	}},
+	{"spa-RVR1960:Dan", "Daniel", "OT", 27, map[int][]string{
+		1: {"En el año tercero del reinado de Joacim rey de Judá, vino Nabucodonosor rey de Babilonia a Jerusalén, y la sitió."},
+	}},
+	{"spa-RVR1960:John", "Juan", "NT", 43, map[int][]string{
+		1: {
+			"En el principio era el Verbo, y el Verbo era con Dios, y el Verbo era Dios.",
+			"Este era en el principio con Dios.",
+		},
+	}},
+	{"spa-RVR1960:1John", "1 Juan", "NT", 62, map[int][]string{
+		4: {
+			"Amados, amémonos unos a otros; porque el amor es de Dios. Todo aquel que ama, es nacido de Dios, y conoce a Dios.",
+			"El que no ama, no ha conocido a Dios; porque Dios es amor.",
+		},
+	}},
+}
+
+// newTestDB returns a migrated Bible.db holding testBooks, with the search
+// index built, in a directory removed when the test ends.
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "Bible.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.MustExec(`CREATE TABLE books (id TEXT PRIMARY KEY, name TEXT, "order" INTEGER, testament TEXT)`)
+	db.MustExec(`CREATE TABLE chapters (chapter INTEGER, id TEXT PRIMARY KEY, osis_end TEXT)`)
+	db.MustExec(`CREATE TABLE verses (id TEXT PRIMARY KEY, chapterId TEXT, cleanText TEXT, reference TEXT, "text" TEXT, chapterNumber INTEGER, verseNumber INTEGER)`)
+	for _, b := range testBooks {
+		db.MustExec(`INSERT INTO books VALUES (?, ?, ?, ?)`, b.id, b.name, b.order, b.testament)
+		for chapter, verses := range b.chapters {
+			chapterId := fmt.Sprintf("%s.%d", b.id, chapter)
+			db.MustExec(`INSERT INTO chapters VALUES (?, ?, ?)`, chapter, chapterId, fmt.Sprintf("%s.%d", chapterId, len(verses)))
+			for i, text := range verses {
+				db.MustExec(`INSERT INTO verses VALUES (?, ?, ?, ?, ?, ?, ?)`,
+					fmt.Sprintf("%s.%d", chapterId, i+1), chapterId, text, fmt.Sprintf("%s %d:%d", b.name, chapter, i+1), text, chapter, i+1)
+			}
+		}
+	}
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureSearchIndex(context.Background(), db, searchAnalyzer); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadVocabulary(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	// Drop what earlier tests cached from their own databases.
+	booksMu.Lock()
+	cachedBooks = nil
+	booksMu.Unlock()
+	return db
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// verseColumns maps every Verse JSON field, in response order, to the column
+// it is read from.
+var verseColumns = []struct {
+	field  string
+	column string
+}{
+	{"id", "id"},
+	{"chapterId", "chapterId"},
+	{"cleanText", "cleanText"},
+	{"reference", "reference"},
+	{"text", `"text"`},
+	{"chapterNumber", "chapterNumber"},
+	{"verseNumber", "verseNumber"},
+}
+
+// verseProjections are named sets of fields that can be passed to fields=
+// instead of listing them one by one.
+var verseProjections = map[string][]string{
+	"minimal": {"id", "text"},
+	"display": {"id", "reference", "text"},
+	"full":    {"id", "chapterId", "cleanText", "reference", "text", "chapterNumber", "verseNumber"},
+}
+
+// allVerseFields selects every field, for callers that need whole verses.
+var allVerseFields = verseSelection(verseProjections["full"])
+
+// VerseFieldsRequest is embedded in the input of every operation that
+// returns verses.
+type VerseFieldsRequest struct {
+	Fields []string `query:"fields" enum:"id,chapterId,cleanText,reference,text,chapterNumber,verseNumber,minimal,display,full" doc:"Campos del versículo a devolver, separados por comas, o una proyección: minimal (id, text), display (id, reference, text) o full (todos, por defecto). El id siempre se incluye."`
+}
+
+// verseSelection is the set of Verse fields a request asked for.
+type verseSelection []string
+
+// selection expands projections and returns the requested fields, which
+// always include id. No fields means the full projection.
+func (r VerseFieldsRequest) selection() verseSelection {
+	if len(r.Fields) == 0 {
+		return verseProjections["full"]
+	}
+	selected := verseSelection{"id"}
+	for _, field := range r.Fields {
+		fields, ok := verseProjections[field]
+		if !ok {
+			fields = []string{field}
+		}
+		for _, f := range fields {
+			if !slices.Contains(selected, f) {
+				selected = append(selected, f)
+			}
+		}
+	}
+	return selected
+}
+
+// columns returns the SQL column list for the selected fields plus any the
+// handler needs itself, like the chapter and verse numbers used to trim
+// ranges.
+func (s verseSelection) columns(needed ...string) string {
+	columns := []string{}
+	for _, c := range verseColumns {
+		if slices.Contains(s, c.field) || slices.Contains(needed, c.field) {
+			columns = append(columns, c.column)
+		}
+	}
+	return strings.Join(columns, ",")
+}
+
+// apply clears the fields that were read but not requested, so they are
+// left out of the response.
+func (s verseSelection) apply(verses []Verse) {
+	for i := range verses {
+		s.applyOne(&verses[i])
+	}
+}
+
+func (s verseSelection) applyOne(v *Verse) {
+	if !slices.Contains(s, "chapterId") {
+		v.ChapterId = ""
+	}
+	if !slices.Contains(s, "cleanText") {
+		v.CleanText = ""
+	}
+	if !slices.Contains(s, "reference") {
+		v.Reference = ""
+	}
+	if !slices.Contains(s, "text") {
+		v.Text = ""
+	}
+	if !slices.Contains(s, "chapterNumber") {
+		v.ChapterNumber = 0
+	}
+	if !slices.Contains(s, "verseNumber") {
+		v.VerseNumber = 0
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Fuzzy search and "did you mean" suggestions work on the vocabulary of the
+// search index: the distinct words of the folded text, which is cleanTextAscii
+// lowercased, keeping "ñ" if the analyzer preserves it. A query word that is
+// not in the vocabulary is replaced by the words a few edits away from it,
+// found through a trigram index, or by the split or merged words it may have
+// been meant as ("hijode", "melqui sedec").
+
+// fewSearchHits is the number of results below which a search returns
+// suggestions.
+const fewSearchHits = 3
+
+// maxSuggestions is the number of suggestions returned for a query.
+const maxSuggestions = 3
+
+// maxAlternatives is the number of vocabulary words a misspelled query word
+// is expanded to in fuzzy searches.
+const maxAlternatives = 8
+
+// vocabularyWord is a word of the search index and the number of verses it
+// appears in.
+type vocabularyWord struct {
+	Term   string `db:"term"`
+	Verses int    `db:"doc"`
+}
+
+// vocabulary holds the words of the search index with a trigram index for
+// finding the ones close to a misspelled word.
+type vocabulary struct {
+	words    []vocabularyWord
+	index    map[string]int
+	trigrams map[string][]int
+}
+
+// searchVocabulary is the vocabulary of the search index, loaded at startup.
+var searchVocabulary = newVocabulary(nil)
+
+func newVocabulary(words []vocabularyWord) *vocabulary {
+	v := &vocabulary{words: words, index: map[string]int{}, trigrams: map[string][]int{}}
+	for i, w := range words {
+		v.index[w.Term] = i
+		for _, t := range trigrams(w.Term) {
+			v.trigrams[t] = append(v.trigrams[t], i)
+		}
+	}
+	return v
+}
+
+// loadVocabulary reads the vocabulary of the search index into
+// searchVocabulary. The index must already be built.
+func loadVocabulary(ctx context.Context, db *sqlx.DB) error {
+	words := []vocabularyWord{}
+	err := dbSelect(ctx, db, "vocabulary", &words, `SELECT term, doc FROM verses_vocabulary WHERE col = 'folded'`)
+	if err != nil {
+		return fmt.Errorf("error while reading search vocabulary: %v", err)
+	}
+	searchVocabulary = newVocabulary(words)
+	return nil
+}
+
+// trigrams returns the distinct trigrams of word, padded with a space on
+// each side so the first and last letters count as much as the others.
+func trigrams(word string) []string {
+	runes := []rune(" " + word + " ")
+	result := []string{}
+	for i := 0; i+3 <= len(runes); i++ {
+		t := string(runes[i : i+3])
+		if !slices.Contains(result, t) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// maxEdits is how many letters may be wrong in a word of the given length:
+// none in short words, where almost any change gives another real word, one
+// up to six letters and two in longer words.
+func maxEdits(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 7:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance returns the Levenshtein distance between a and b, or limit+1
+// if it is greater than limit.
+func editDistance(a, b []rune, limit int) int {
+	if len(a)-len(b) > limit || len(b)-len(a) > limit {
+		return limit + 1
+	}
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// correction is what a query word may have been meant as: a vocabulary word,
+// or two of them when the word was missing a space, how many edits away it
+// is, and how many query words it replaces, two when the word and the next
+// one were meant as a single word.
+type correction struct {
+	Term     string
+	Edits    int
+	Verses   int
+	Consumed int
+}
+
+func compareCorrections(a, b correction) int {
+	if a.Edits != b.Edits {
+		return a.Edits - b.Edits
+	}
+	if a.Verses != b.Verses {
+		return b.Verses - a.Verses
+	}
+	return strings.Compare(a.Term, b.Term)
+}
+
+// known reports whether word is in the vocabulary.
+func (v *vocabulary) known(word string) bool {
+	_, ok := v.index[word]
+	return ok
+}
+
+// similar returns the vocabulary words within maxEdits of word, closest and
+// most common first.
+func (v *vocabulary) similar(word string) []correction {
+	runes := []rune(word)
+	limit := maxEdits(len(runes))
+	if limit == 0 {
+		if i, ok := v.index[word]; ok {
+			return []correction{{Term: word, Verses: v.words[i].Verses, Consumed: 1}}
+		}
+		return nil
+	}
+	// Each edit changes at most three trigrams, so a close word shares
+	// all the others.
+	wordTrigrams := trigrams(word)
+	needed := max(1, len(wordTrigrams)-3*limit)
+	shared := map[int]int{}
+	for _, t := range wordTrigrams {
+		for _, i := range v.trigrams[t] {
+			shared[i]++
+		}
+	}
+	result := []correction{}
+	for i, count := range shared {
+		if count < needed {
+			continue
+		}
+		w := v.words[i]
+		if edits := editDistance(runes, []rune(w.Term), limit); edits <= limit {
+			result = append(result, correction{Term: w.Term, Edits: edits, Verses: w.Verses, Consumed: 1})
+		}
+	}
+	slices.SortFunc(result, compareCorrections)
+	return result
+}
+
+// splits returns the ways word can be split into two vocabulary words, as
+// when a space was left out.
+func (v *vocabulary) splits(word string) []correction {
+	result := []correction{}
+	for i := range word {
+		if i == 0 || !v.known(word[:i]) || !v.known(word[i:]) {
+			continue
+		}
+		verses := min(v.words[v.index[word[:i]]].Verses, v.words[v.index[word[i:]]].Verses)
+		result = append(result, correction{Term: word[:i] + " " + word[i:], Edits: 1, Verses: verses, Consumed: 1})
+	}
+	return result
+}
+
+// corrections returns, for each word of query in order, what it may have been
+// meant as, best first. Words in the vocabulary are kept as they are. Words
+// that are not get their close vocabulary words, their splits and, merged
+// with the next word, the vocabulary words close to both together; a word
+// with no corrections is kept as it is and matches nothing.
+func (v *vocabulary) corrections(query string) [][]correction {
+	tokens := words(searchAnalyzer.fold(query))
+	result := [][]correction{}
+	for i := 0; i < len(tokens); {
+		token := tokens[i]
+		if v.known(token) {
+			result = append(result, []correction{{Term: token, Verses: v.words[v.index[token]].Verses, Consumed: 1}})
+			i++
+			continue
+		}
+		alternatives := append(v.similar(token), v.splits(token)...)
+		if i+1 < len(tokens) {
+			for _, merged := range v.similar(token + tokens[i+1]) {
+				merged.Consumed = 2
+				alternatives = append(alternatives, merged)
+			}
+		}
+		slices.SortFunc(alternatives, compareCorrections)
+		if len(alternatives) == 0 {
+			alternatives = []correction{{Term: token, Edits: 0, Consumed: 1}}
+		}
+		if len(alternatives) > maxAlternatives {
+			alternatives = alternatives[:maxAlternatives]
+		}
+		// Merging with the next word only counts if it is the best
+		// reading; otherwise the next word is corrected on its own.
+		if alternatives[0].Consumed == 2 {
+			alternatives = slices.DeleteFunc(alternatives, func(c correction) bool { return c.Consumed != 2 })
+		} else {
+			alternatives = slices.DeleteFunc(alternatives, func(c correction) bool { return c.Consumed != 1 })
+		}
+		result = append(result, alternatives)
+		i += alternatives[0].Consumed
+	}
+	return result
+}
+
+// matchExpression returns the FTS5 query matching verses with every word of
+// query or one of its corrections, or "" if query has no words.
+func (v *vocabulary) matchExpression(query string) string {
+	groups := []string{}
+	for _, alternatives := range v.corrections(query) {
+		terms := make([]string, len(alternatives))
+		for i, c := range alternatives {
+			terms[i] = `"` + c.Term + `"`
+		}
+		groups = append(groups, "("+strings.Join(terms, " OR ")+")")
+	}
+	return strings.Join(groups, " AND ")
+}
+
+// suggest returns up to limit corrected versions of query, most likely
+// first, or none if every word of query is in the vocabulary.
+func (v *vocabulary) suggest(query string, limit int) []string {
+	groups := v.corrections(query)
+	corrected := false
+	for _, alternatives := range groups {
+		if alternatives[0].Edits > 0 || alternatives[0].Consumed > 1 {
+			corrected = true
+		}
+	}
+	if !corrected {
+		return nil
+	}
+	suggestions := []string{}
+	for k := 0; k < limit; k++ {
+		terms := make([]string, len(groups))
+		for i, alternatives := range groups {
+			terms[i] = alternatives[min(k, len(alternatives)-1)].Term
+		}
+		suggestion := strings.Join(terms, " ")
+		if !slices.Contains(suggestions, suggestion) {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions
+}
+
+// SearchSuggestion is a corrected query and how many verses it finds.
+type SearchSuggestion struct {
+	Query string `json:"query" doc:"Búsqueda corregida"`
+	Hits  int    `json:"hits" doc:"Número de versículos que encuentra la búsqueda corregida en el mismo modo"`
+}
+
+// searchSuggestions returns the corrections of query that find verses in
+// mode, most likely first.
+func searchSuggestions(ctx context.Context, db *sqlx.DB, query, mode string) ([]SearchSuggestion, error) {
+	suggestions := []SearchSuggestion{}
+	for _, suggestion := range searchVocabulary.suggest(query, maxSuggestions) {
+		hits, err := countSearch(ctx, db, suggestion, mode)
+		if err != nil {
+			return nil, err
+		}
+		if hits > 0 {
+			suggestions = append(suggestions, SearchSuggestion{Query: suggestion, Hits: hits})
+		}
+	}
+	return suggestions, nil
+}
+
+// didYouMean returns the suggestions worth showing for a search in mode that
+// found hits verses: none if it found enough, or was already fuzzy, and
+// otherwise the ones that find more.
+func didYouMean(ctx context.Context, db *sqlx.DB, query, mode string, hits int) ([]string, error) {
+	if hits >= fewSearchHits || mode == SearchFuzzy {
+		return nil, nil
+	}
+	suggestions, err := searchSuggestions(ctx, db, query, mode)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, s := range suggestions {
+		if s.Hits > hits {
+			result = append(result, s.Query)
+		}
+	}
+	return result, nil
+}
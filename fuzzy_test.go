@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"amor", "amor", 2, 0},
+		{"amor", "amo", 2, 1},
+		{"amor", "amar", 2, 1},
+		{"amor", "mora", 2, 2},
+		{"", "luz", 3, 3},
+		{"kitten", "sitting", 3, 3},
+		// Accented letters are single runes, one edit away from the plain
+		// letter.
+		{"niño", "nino", 1, 1},
+		{"espíritu", "espiritu", 2, 1},
+		{"jerusalén", "jerusalen", 0, 1},
+		// Over the limit, by length or by content, it is limit+1.
+		{"abcdef", "a", 2, 3},
+		{"abc", "xyz", 1, 2},
+		{"principio", "principe", 1, 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b), tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+		if got := editDistance([]rune(tt.b), []rune(tt.a), tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.b, tt.a, tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	"Todos los libros de la Biblia en orden canónico."
+	books: [Book!]!
+	"Un libro por su ID, ej: spa-RVR1960:Gen."
+	book(id: ID!): Book
+	"Un capítulo por su ID, ej: spa-RVR1960:Gen.1."
+	chapter(id: ID!): Chapter
+	"Un versículo por su ID, ej: spa-RVR1960:Gen.1.1."
+	verse(id: ID!): Verse
+	"Los versículos de una referencia o rango, ej: Juan 3:16-18 o spa-RVR1960:Gen.1.1-Gen.2.3."
+	passage(ref: String!): [Verse!]!
+	"Versículos que contienen el texto, sin distinguir acentos. Requiere el alcance search."
+	search(query: String!, limit: Int = 100): [Verse!]!
+}
+
+type Book {
+	id: ID!
+	name: String!
+	order: Int!
+	testament: String!
+	chapters: [Chapter!]!
+	"Un capítulo del libro por su número."
+	chapter(number: Int!): Chapter
+}
+
+type Chapter {
+	id: ID!
+	number: Int!
+	"ID del último versículo del capítulo."
+	osisEnd: String!
+	book: Book!
+	verses: [Verse!]!
+	"El capítulo anterior del mismo libro."
+	previous: Chapter
+	"El capítulo siguiente del mismo libro."
+	next: Chapter
+}
+
+type Verse {
+	id: ID!
+	chapterId: ID!
+	reference: String!
+	text: String!
+	cleanText: String!
+	chapterNumber: Int!
+	verseNumber: Int!
+	book: Book!
+	chapter: Chapter!
+	"El versículo anterior, aunque esté en otro capítulo o libro."
+	previous: Verse
+	"El versículo siguiente, aunque esté en otro capítulo o libro."
+	next: Verse
+}
+`
+
+// maxGraphQLSearchResults caps search(limit:), since every result can fan
+// out into further nested lookups.
+const maxGraphQLSearchResults = 500
+
+// maxGraphQLResults caps how many books, chapters and verses the lists of one
+// GraphQL request can return altogether, so nesting them, as in
+// books { chapters { verses } }, cannot fetch the whole Bible at once.
+const maxGraphQLResults = 5000
+
+// graphqlLoaders batch the lookups made while resolving one GraphQL request,
+// so resolving, say, the book of 50 verses is one query instead of 50.
+// They also cache within the request.
+type graphqlLoaders struct {
+	books          *dataloader.Loader[string, *Book]
+	bookChapters   *dataloader.Loader[string, []Chapter]
+	chapters       *dataloader.Loader[string, *Chapter]
+	chapterVerses  *dataloader.Loader[string, []Verse]
+	verses         *dataloader.Loader[string, *Verse]
+	nextVerses     *dataloader.Loader[string, *AdjacentVerse]
+	previousVerses *dataloader.Loader[string, *AdjacentVerse]
+
+	// results counts the objects returned by lists so far.
+	results atomic.Int64
+}
+
+// loaderWait is how long a loader waits to collect keys before querying.
+const loaderWait = time.Millisecond
+
+// newLoader returns a loader that fetches many rows at once and hands each
+// key the row whose key matches, or nil if there is none.
+func newLoader[T any](fetch func(context.Context, []string) ([]T, error), key func(T) string) *dataloader.Loader[string, *T] {
+	return dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[*T] {
+		rows, err := fetch(ctx, keys)
+		byKey := map[string]*T{}
+		for i := range rows {
+			byKey[key(rows[i])] = &rows[i]
+		}
+		results := make([]*dataloader.Result[*T], len(keys))
+		for i, k := range keys {
+			results[i] = &dataloader.Result[*T]{Data: byKey[k], Error: err}
+		}
+		return results
+	}, dataloader.WithWait[string, *T](loaderWait))
+}
+
+// newGroupLoader returns a loader that fetches many rows at once and hands
+// each key all the rows whose key matches, in the order they were read.
+func newGroupLoader[T any](fetch func(context.Context, []string) ([]T, error), key func(T) string) *dataloader.Loader[string, []T] {
+	return dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[[]T] {
+		rows, err := fetch(ctx, keys)
+		byKey := map[string][]T{}
+		for _, row := range rows {
+			byKey[key(row)] = append(byKey[key(row)], row)
+		}
+		results := make([]*dataloader.Result[[]T], len(keys))
+		for i, k := range keys {
+			results[i] = &dataloader.Result[[]T]{Data: byKey[k], Error: err}
+		}
+		return results
+	}, dataloader.WithWait[string, []T](loaderWait))
+}
+
+func chapterBookId(c Chapter) string {
+	bookId, _, _ := splitChapterId(c.ID)
+	return bookId
+}
+
+func newGraphQLLoaders(db *sqlx.DB) *graphqlLoaders {
+	return &graphqlLoaders{
+		books: newLoader(func(ctx context.Context, ids []string) ([]Book, error) {
+			return booksByIds(ctx, db, ids)
+		}, func(b Book) string { return b.ID }),
+		bookChapters: newGroupLoader(func(ctx context.Context, ids []string) ([]Chapter, error) {
+			return chaptersByBookIds(ctx, db, ids)
+		}, chapterBookId),
+		chapters: newLoader(func(ctx context.Context, ids []string) ([]Chapter, error) {
+			return chaptersByIds(ctx, db, ids)
+		}, func(c Chapter) string { return c.ID }),
+		chapterVerses: newGroupLoader(func(ctx context.Context, ids []string) ([]Verse, error) {
+			return versesByChapterIds(ctx, db, ids)
+		}, func(v Verse) string { return v.ChapterId }),
+		verses: newLoader(func(ctx context.Context, ids []string) ([]Verse, error) {
+			return versesByIds(ctx, db, ids)
+		}, func(v Verse) string { return v.ID }),
+		nextVerses: newLoader(func(ctx context.Context, ids []string) ([]AdjacentVerse, error) {
+			return adjacentVerses(ctx, db, ids, 1)
+		}, func(v AdjacentVerse) string { return v.FromID }),
+		previousVerses: newLoader(func(ctx context.Context, ids []string) ([]AdjacentVerse, error) {
+			return adjacentVerses(ctx, db, ids, -1)
+		}, func(v AdjacentVerse) string { return v.FromID }),
+	}
+}
+
+type graphqlLoadersKey struct{}
+
+func loadersFrom(ctx context.Context) *graphqlLoaders {
+	return ctx.Value(graphqlLoadersKey{}).(*graphqlLoaders)
+}
+
+// chargeResults adds n objects returned by a list to the count of the request
+// of ctx, failing once it goes over maxGraphQLResults.
+func chargeResults(ctx context.Context, n int) error {
+	if loadersFrom(ctx).results.Add(int64(n)) > maxGraphQLResults {
+		return fmt.Errorf("the query returns more than %d books, chapters and verses, ask for fewer at once", maxGraphQLResults)
+	}
+	return nil
+}
+
+// errInternal is returned to GraphQL clients instead of database errors,
+// which are logged.
+var errInternal = errors.New("internal error")
+
+func internalError(ctx context.Context, err error) error {
+	requestLogger(ctx).ErrorContext(ctx, "error while resolving GraphQL query", "error", err)
+	return errInternal
+}
+
+// graphqlResolver resolves the Query type. The other types are resolved by
+// the wrappers below, which fetch related objects through the loaders.
+type graphqlResolver struct {
+	db *sqlx.DB
+}
+
+func (r *graphqlResolver) Books(ctx context.Context) ([]*bookResolver, error) {
+	books, err := listBooks(ctx, r.db)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if err := chargeResults(ctx, len(books)); err != nil {
+		return nil, err
+	}
+	resolvers := make([]*bookResolver, len(books))
+	for i := range books {
+		resolvers[i] = &bookResolver{&books[i]}
+	}
+	return resolvers, nil
+}
+
+func (r *graphqlResolver) Book(ctx context.Context, args struct{ ID graphql.ID }) (*bookResolver, error) {
+	return loadBook(ctx, string(args.ID))
+}
+
+func (r *graphqlResolver) Chapter(ctx context.Context, args struct{ ID graphql.ID }) (*chapterResolver, error) {
+	return loadChapter(ctx, string(args.ID))
+}
+
+func (r *graphqlResolver) Verse(ctx context.Context, args struct{ ID graphql.ID }) (*verseResolver, error) {
+	verse, err := loadersFrom(ctx).verses.Load(ctx, string(args.ID))()
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if verse == nil {
+		return nil, nil
+	}
+	return &verseResolver{verse}, nil
+}
+
+func (r *graphqlResolver) Passage(ctx context.Context, args struct{ Ref string }) ([]*verseResolver, error) {
+	results, err := lookupBatch(ctx, r.db, []string{args.Ref}, allVerseFields)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	result := results[args.Ref]
+	if result.Error != nil {
+		return nil, errors.New(result.Error.Detail)
+	}
+	if err := chargeResults(ctx, len(result.Verses)); err != nil {
+		return nil, err
+	}
+	return verseResolvers(result.Verses), nil
+}
+
+func (r *graphqlResolver) Search(ctx context.Context, args struct {
+	Query string
+	Limit int32
+}) ([]*verseResolver, error) {
+	if !slices.Contains(requestInfoFrom(ctx).Scopes, ScopeSearch) {
+		return nil, fmt.Errorf("search requires an API key with the %q scope", ScopeSearch)
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return nil, errors.New("query is required")
+	}
+	if args.Limit < 1 || args.Limit > maxGraphQLSearchResults {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxGraphQLSearchResults)
+	}
+	if err := chargeRateLimit(ctx, routeClassSearch); err != nil {
+		return nil, err
+	}
+	verses, err := searchVersesInBooks(ctx, r.db, args.Query, SearchAccentInsensitive, nil, int(args.Limit), allVerseFields)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	searchResults.WithLabelValues(SearchAccentInsensitive).Observe(float64(len(verses)))
+	if err := chargeResults(ctx, len(verses)); err != nil {
+		return nil, err
+	}
+	return verseResolvers(verses), nil
+}
+
+func loadBook(ctx context.Context, id string) (*bookResolver, error) {
+	book, err := loadersFrom(ctx).books.Load(ctx, id)()
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if book == nil {
+		return nil, nil
+	}
+	return &bookResolver{book}, nil
+}
+
+func loadChapter(ctx context.Context, id string) (*chapterResolver, error) {
+	chapter, err := loadersFrom(ctx).chapters.Load(ctx, id)()
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if chapter == nil {
+		return nil, nil
+	}
+	return &chapterResolver{chapter}, nil
+}
+
+type bookResolver struct {
+	book *Book
+}
+
+func (b *bookResolver) ID() graphql.ID    { return graphql.ID(b.book.ID) }
+func (b *bookResolver) Name() string      { return b.book.Name }
+func (b *bookResolver) Order() int32      { return int32(b.book.Order) }
+func (b *bookResolver) Testament() string { return b.book.Testament }
+
+func (b *bookResolver) Chapters(ctx context.Context) ([]*chapterResolver, error) {
+	chapters := b.book.Chapters
+	if chapters == nil {
+		var err error
+		chapters, err = loadersFrom(ctx).bookChapters.Load(ctx, b.book.ID)()
+		if err != nil {
+			return nil, internalError(ctx, err)
+		}
+	}
+	if err := chargeResults(ctx, len(chapters)); err != nil {
+		return nil, err
+	}
+	resolvers := make([]*chapterResolver, len(chapters))
+	for i := range chapters {
+		resolvers[i] = &chapterResolver{&chapters[i]}
+	}
+	return resolvers, nil
+}
+
+func (b *bookResolver) Chapter(ctx context.Context, args struct{ Number int32 }) (*chapterResolver, error) {
+	return loadChapter(ctx, fmt.Sprintf("%s.%d", b.book.ID, args.Number))
+}
+
+type chapterResolver struct {
+	chapter *Chapter
+}
+
+func (c *chapterResolver) ID() graphql.ID  { return graphql.ID(c.chapter.ID) }
+func (c *chapterResolver) Number() int32   { return int32(c.chapter.Chapter) }
+func (c *chapterResolver) OsisEnd() string { return c.chapter.Osis_End }
+
+func (c *chapterResolver) Book(ctx context.Context) (*bookResolver, error) {
+	book, err := loadBook(ctx, chapterBookId(*c.chapter))
+	if err == nil && book == nil {
+		err = internalError(ctx, fmt.Errorf("book of chapter %s not found", c.chapter.ID))
+	}
+	return book, err
+}
+
+// Verses are charged before they are loaded, counted from the number of the
+// last verse of the chapter, so a query over the limit stops loading them.
+func (c *chapterResolver) Verses(ctx context.Context) ([]*verseResolver, error) {
+	if _, count, ok := splitChapterId(c.chapter.Osis_End); ok {
+		if err := chargeResults(ctx, count); err != nil {
+			return nil, err
+		}
+	}
+	verses, err := loadersFrom(ctx).chapterVerses.Load(ctx, c.chapter.ID)()
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return verseResolvers(verses), nil
+}
+
+func (c *chapterResolver) Previous(ctx context.Context) (*chapterResolver, error) {
+	if c.chapter.Chapter <= 1 {
+		return nil, nil
+	}
+	return loadChapter(ctx, fmt.Sprintf("%s.%d", chapterBookId(*c.chapter), c.chapter.Chapter-1))
+}
+
+func (c *chapterResolver) Next(ctx context.Context) (*chapterResolver, error) {
+	return loadChapter(ctx, fmt.Sprintf("%s.%d", chapterBookId(*c.chapter), c.chapter.Chapter+1))
+}
+
+type verseResolver struct {
+	verse *Verse
+}
+
+func verseResolvers(verses []Verse) []*verseResolver {
+	resolvers := make([]*verseResolver, len(verses))
+	for i := range verses {
+		resolvers[i] = &verseResolver{&verses[i]}
+	}
+	return resolvers
+}
+
+func (v *verseResolver) ID() graphql.ID        { return graphql.ID(v.verse.ID) }
+func (v *verseResolver) ChapterId() graphql.ID { return graphql.ID(v.verse.ChapterId) }
+func (v *verseResolver) Reference() string     { return v.verse.Reference }
+func (v *verseResolver) Text() string          { return v.verse.Text }
+func (v *verseResolver) CleanText() string     { return v.verse.CleanText }
+func (v *verseResolver) ChapterNumber() int32  { return int32(v.verse.ChapterNumber) }
+func (v *verseResolver) VerseNumber() int32    { return int32(v.verse.VerseNumber) }
+
+func (v *verseResolver) Book(ctx context.Context) (*bookResolver, error) {
+	book, err := loadBook(ctx, chapterBookId(Chapter{ID: v.verse.ChapterId}))
+	if err == nil && book == nil {
+		err = internalError(ctx, fmt.Errorf("book of verse %s not found", v.verse.ID))
+	}
+	return book, err
+}
+
+func (v *verseResolver) Chapter(ctx context.Context) (*chapterResolver, error) {
+	chapter, err := loadChapter(ctx, v.verse.ChapterId)
+	if err == nil && chapter == nil {
+		err = internalError(ctx, fmt.Errorf("chapter of verse %s not found", v.verse.ID))
+	}
+	return chapter, err
+}
+
+func (v *verseResolver) Previous(ctx context.Context) (*verseResolver, error) {
+	return loadAdjacent(ctx, loadersFrom(ctx).previousVerses, v.verse.ID)
+}
+
+func (v *verseResolver) Next(ctx context.Context) (*verseResolver, error) {
+	return loadAdjacent(ctx, loadersFrom(ctx).nextVerses, v.verse.ID)
+}
+
+func loadAdjacent(ctx context.Context, loader *dataloader.Loader[string, *AdjacentVerse], id string) (*verseResolver, error) {
+	adjacent, err := loader.Load(ctx, id)()
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if adjacent == nil {
+		return nil, nil
+	}
+	return &verseResolver{&adjacent.Verse}, nil
+}
+
+// graphqlRequest is a GraphQL-over-HTTP request, sent as a JSON body or, for
+// GET, as query parameters.
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// newGraphQLHandler returns the handler for /graphql. It requires the read
+// scope, like the REST lookups; search also needs the search scope. Outside
+// production, browsers opening it get GraphiQL.
+func newGraphQLHandler(db *sqlx.DB, cfg Config) http.Handler {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{db: db},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(12),
+		graphql.MaxParallelism(32),
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		requestInfoFrom(ctx).OperationID = "graphql"
+		trace.SpanFromContext(ctx).SetName("graphql")
+
+		if r.Method == http.MethodGet && !cfg.IsProduction() && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(graphiqlPage))
+			return
+		}
+
+		request := graphqlRequest{}
+		switch r.Method {
+		case http.MethodGet:
+			request.Query = r.URL.Query().Get("query")
+			request.OperationName = r.URL.Query().Get("operationName")
+			if variables := r.URL.Query().Get("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+					writeProblem(w, http.StatusBadRequest, "variables must be a JSON object")
+					return
+				}
+			}
+		case http.MethodPost:
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+				writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid GraphQL request body: %v", err))
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeProblem(w, http.StatusMethodNotAllowed, "use GET or POST")
+			return
+		}
+		if request.Query == "" {
+			writeProblem(w, http.StatusBadRequest, "query is required")
+			return
+		}
+
+		status, message, err := checkAccess(ctx, db, requestAPIKey(r), ScopeRead, cfg.Auth.AnonymousScopes)
+		if err != nil {
+			requestLogger(ctx).ErrorContext(ctx, message, "error", err)
+		}
+		if status != 0 {
+			writeProblem(w, status, message)
+			return
+		}
+
+		ctx = context.WithValue(ctx, graphqlLoadersKey{}, newGraphQLLoaders(db))
+		response := schema.Exec(ctx, request.Query, request.OperationName, request.Variables)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+const graphiqlPage = `<!doctype html>
+<html lang="es">
+<head>
+	<meta charset="utf-8">
+	<title>RV 1960 API - GraphiQL</title>
+	<link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+</head>
+<body style="margin: 0">
+	<div id="graphiql" style="height: 100vh"></div>
+	<script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+	<script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+	<script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+	<script>
+		ReactDOM.createRoot(document.getElementById("graphiql")).render(
+			React.createElement(GraphiQL, {
+				fetcher: GraphiQL.createFetcher({ url: window.location.pathname }),
+				defaultQuery: "{\n  book(id: \"spa-RVR1960:Gen\") {\n    name\n    chapter(number: 1) {\n      verses { reference text }\n      next { id }\n    }\n  }\n}\n",
+			}),
+		);
+	</script>
+</body>
+</html>
+`
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/samueldelacruz/spanish-bible-api-demo/biblepb"
+)
+
+// bibleServer implements the gRPC BibleService with the same queries as the
+// REST handlers.
+type bibleServer struct {
+	biblepb.UnimplementedBibleServiceServer
+	db *sqlx.DB
+}
+
+func bookToProto(b Book) *biblepb.Book {
+	book := &biblepb.Book{
+		Id:        b.ID,
+		Name:      b.Name,
+		Order:     int32(b.Order),
+		Testament: b.Testament,
+	}
+	for _, c := range b.Chapters {
+		book.Chapters = append(book.Chapters, chapterToProto(c))
+	}
+	return book
+}
+
+func chapterToProto(c Chapter) *biblepb.Chapter {
+	return &biblepb.Chapter{Number: int32(c.Chapter), Id: c.ID, OsisEnd: c.Osis_End}
+}
+
+func verseToProto(v Verse) *biblepb.Verse {
+	return &biblepb.Verse{
+		Id:            v.ID,
+		ChapterId:     v.ChapterId,
+		CleanText:     v.CleanText,
+		Reference:     v.Reference,
+		Text:          v.Text,
+		ChapterNumber: int32(v.ChapterNumber),
+		VerseNumber:   int32(v.VerseNumber),
+	}
+}
+
+func versesToProto(verses []Verse) []*biblepb.Verse {
+	result := make([]*biblepb.Verse, len(verses))
+	for i, v := range verses {
+		result[i] = verseToProto(v)
+	}
+	return result
+}
+
+// grpcInternal logs a database error and returns a generic Internal status,
+// so clients do not see query details.
+func grpcInternal(ctx context.Context, err error) error {
+	requestLogger(ctx).ErrorContext(ctx, "error while serving gRPC request", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func (s *bibleServer) ListBooks(ctx context.Context, req *biblepb.ListBooksRequest) (*biblepb.ListBooksResponse, error) {
+	books, err := listBooks(ctx, s.db)
+	if err != nil {
+		return nil, grpcInternal(ctx, err)
+	}
+	response := &biblepb.ListBooksResponse{}
+	for _, b := range books {
+		response.Books = append(response.Books, bookToProto(b))
+	}
+	return response, nil
+}
+
+func (s *bibleServer) GetBook(ctx context.Context, req *biblepb.GetBookRequest) (*biblepb.Book, error) {
+	book, err := getBook(ctx, s.db, req.GetBookId())
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "book not found: %s", req.GetBookId())
+	}
+	if err != nil {
+		return nil, grpcInternal(ctx, err)
+	}
+	return bookToProto(book), nil
+}
+
+func (s *bibleServer) GetChapter(ctx context.Context, req *biblepb.GetChapterRequest) (*biblepb.GetChapterResponse, error) {
+	chapterId := fmt.Sprintf("%s.%d", req.GetBookId(), req.GetChapter())
+	chapters, err := chaptersByIds(ctx, s.db, []string{chapterId})
+	if err != nil {
+		return nil, grpcInternal(ctx, err)
+	}
+	if len(chapters) == 0 {
+		return nil, status.Errorf(codes.NotFound, "chapter not found: %s", chapterId)
+	}
+	verses, err := chapterVerses(ctx, s.db, chapterId, allVerseFields)
+	if err != nil {
+		return nil, grpcInternal(ctx, err)
+	}
+	return &biblepb.GetChapterResponse{
+		Chapter: chapterToProto(chapters[0]),
+		Verses:  versesToProto(verses),
+	}, nil
+}
+
+func (s *bibleServer) GetVerse(ctx context.Context, req *biblepb.GetVerseRequest) (*biblepb.Verse, error) {
+	verse, err := getVerse(ctx, s.db, req.GetId(), allVerseFields)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "verse not found: %s", req.GetId())
+	}
+	if err != nil {
+		return nil, grpcInternal(ctx, err)
+	}
+	return verseToProto(verse), nil
+}
+
+func (s *bibleServer) GetRange(ctx context.Context, req *biblepb.GetRangeRequest) (*biblepb.GetRangeResponse, error) {
+	results, err := lookupBatch(ctx, s.db, []string{req.GetReference()}, allVerseFields)
+	if err != nil {
+		return nil, grpcInternal(ctx, err)
+	}
+	result := results[req.GetReference()]
+	if result.Error != nil {
+		code := codes.InvalidArgument
+		if result.Error.Status == http.StatusNotFound {
+			code = codes.NotFound
+		}
+		return nil, status.Error(code, result.Error.Detail)
+	}
+	return &biblepb.GetRangeResponse{
+		Passage: result.Passage,
+		Verses:  versesToProto(result.Verses),
+	}, nil
+}
+
+// errSearchLimit stops a search stream once the requested number of verses
+// has been sent.
+var errSearchLimit = errors.New("search limit reached")
+
+func (s *bibleServer) Search(req *biblepb.SearchRequest, stream grpc.ServerStreamingServer[biblepb.Verse]) error {
+	ctx := stream.Context()
+	if req.GetQuery() == "" {
+		return status.Error(codes.InvalidArgument, "query is required")
+	}
+	if req.GetLimit() < 0 {
+		return status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	sent := 0
+	err := streamSearch(ctx, s.db, req.GetQuery(), SearchAccentInsensitive, allVerseFields, func(v Verse) error {
+		if err := stream.Send(verseToProto(v)); err != nil {
+			return err
+		}
+		sent++
+		if req.GetLimit() > 0 && sent >= int(req.GetLimit()) {
+			return errSearchLimit
+		}
+		return nil
+	})
+	searchResults.WithLabelValues(SearchAccentInsensitive).Observe(float64(sent))
+	if err != nil && err != errSearchLimit {
+		if _, ok := status.FromError(err); ok || ctx.Err() != nil {
+			return err
+		}
+		return grpcInternal(ctx, err)
+	}
+	return nil
+}
+
+// grpcScopes are the scopes the BibleService methods require; the rest need
+// ScopeRead.
+var grpcScopes = map[string]string{
+	biblepb.BibleService_Search_FullMethodName: ScopeSearch,
+}
+
+// grpcRouteClasses are the rate limit classes of the BibleService methods,
+// matching the REST endpoints they mirror; the rest are reads.
+var grpcRouteClasses = map[string]string{
+	biblepb.BibleService_GetRange_FullMethodName: routeClassExport,
+	biblepb.BibleService_Search_FullMethodName:   routeClassSearch,
+}
+
+// grpcCodes maps the statuses returned by checkAccess to gRPC codes.
+var grpcCodes = map[int]codes.Code{
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusInternalServerError: codes.Internal,
+}
+
+// grpcRateLimit takes a token for a gRPC call from the bucket of its client
+// and method class, keyed by API key when a valid one is sent and by peer
+// address otherwise, like the HTTP rate limiter.
+func grpcRateLimit(ctx context.Context, rl *rateLimiter, fullMethod, key string) error {
+	identity := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		identity = p.Addr.String()
+		if host, _, err := net.SplitHostPort(identity); err == nil {
+			identity = host
+		}
+	}
+	identity = "ip:" + identity
+	keyID := rl.apiKeyID(ctx, key)
+	if keyID != "" {
+		identity = "key:" + keyID
+	}
+	class := routeClassRead
+	if c, ok := grpcRouteClasses[fullMethod]; ok {
+		class = c
+	}
+	result := rl.check(identity, class, keyID != "")
+	if result.allowed {
+		return nil
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(result.retryAfter())))
+	return status.Error(codes.ResourceExhausted, result.Error())
+}
+
+// grpcAccess attaches a requestInfo to the context of a gRPC call, applies
+// the rate limits when rl is not nil, checks its API key, sent in the
+// x-api-key metadata, like the HTTP API does, and writes an access log line
+// once it is done.
+func grpcAccess(ctx context.Context, fullMethod string, anonymousScopes []string, db *sqlx.DB, rl *rateLimiter, call func(context.Context) error) error {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+	info := &requestInfo{OperationID: path.Base(fullMethod)}
+	if ids := md.Get("x-request-id"); len(ids) > 0 && validRequestId(ids[0]) {
+		info.RequestID = ids[0]
+	} else {
+		info.RequestID = newRequestId()
+	}
+	ctx = context.WithValue(ctx, requestInfoKey{}, info)
+
+	err := func() error {
+		key := ""
+		if keys := md.Get("x-api-key"); len(keys) > 0 {
+			key = keys[0]
+		}
+		if fullMethod == healthpb.Health_Check_FullMethodName || fullMethod == healthpb.Health_Watch_FullMethodName {
+			return call(ctx)
+		}
+		if rl != nil {
+			if err := grpcRateLimit(ctx, rl, fullMethod, key); err != nil {
+				return err
+			}
+		}
+		scope := ScopeRead
+		if s, ok := grpcScopes[fullMethod]; ok {
+			scope = s
+		}
+		httpStatus, message, err := checkAccess(ctx, db, key, scope, anonymousScopes)
+		if err != nil {
+			requestLogger(ctx).ErrorContext(ctx, message, "error", err)
+		}
+		if httpStatus != 0 {
+			return status.Error(grpcCodes[httpStatus], message)
+		}
+		return call(ctx)
+	}()
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+	}
+	requestLogger(ctx).LogAttrs(ctx, level, "grpc request",
+		slog.String("method", fullMethod),
+		slog.String("operation", info.operationLabel()),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("api_key", info.APIKeyID),
+	)
+	return err
+}
+
+// contextStream replaces the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// newGRPCServer returns the gRPC server with the BibleService, the standard
+// health service and reflection, so tools like grpcurl can list the
+// methods. Calls are rate limited by rl, if it is not nil.
+func newGRPCServer(db *sqlx.DB, cfg Config, rl *rateLimiter) *grpc.Server {
+	anonymousScopes := cfg.Auth.AnonymousScopes
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			var resp any
+			err := grpcAccess(ctx, info.FullMethod, anonymousScopes, db, rl, func(ctx context.Context) error {
+				var err error
+				resp, err = handler(ctx, req)
+				return err
+			})
+			return resp, err
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return grpcAccess(ss.Context(), info.FullMethod, anonymousScopes, db, rl, func(ctx context.Context) error {
+				return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+			})
+		}),
+	)
+	biblepb.RegisterBibleServiceServer(server, &bibleServer{db: db})
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	return server
+}
+
+// grpcSideServer runs server alongside the HTTP API on cfg.GRPCAddr.
+func grpcSideServer(server *grpc.Server, cfg Config) sideServer {
+	return sideServer{
+		name:  "grpc",
+		addr:  cfg.GRPCAddr,
+		serve: server.Serve,
+		shutdown: func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				server.Stop()
+				return ctx.Err()
+			}
+		},
+	}
+}
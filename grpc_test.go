@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/samueldelacruz/spanish-bible-api-demo/biblepb"
+)
+
+// newTestGRPCClient serves newGRPCServer over an in-memory connection and
+// returns a client for it.
+func newTestGRPCClient(t *testing.T, db *sqlx.DB, rl *rateLimiter) biblepb.BibleServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer(db, defaultConfig(), rl)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return biblepb.NewBibleServiceClient(conn)
+}
+
+// searchIds runs a streaming search and returns the IDs of the verses sent.
+func searchIds(client biblepb.BibleServiceClient, query string, limit int32) ([]string, error) {
+	stream, err := client.Search(context.Background(), &biblepb.SearchRequest{Query: query, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for {
+		verse, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return ids, nil
+		}
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, verse.GetId())
+	}
+}
+
+func TestGRPCGetVerse(t *testing.T) {
+	client := newTestGRPCClient(t, newTestDB(t), nil)
+
+	verse, err := client.GetVerse(context.Background(), &biblepb.GetVerseRequest{Id: "spa-RVR1960:Gen.1.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verse.GetReference() != "Génesis 1:3" || verse.GetText() != "Y dijo Dios: Sea la luz; y fue la luz." {
+		t.Errorf("got %s %q", verse.GetReference(), verse.GetText())
+	}
+	if verse.GetChapterNumber() != 1 || verse.GetVerseNumber() != 3 {
+		t.Errorf("got chapter %d verse %d", verse.GetChapterNumber(), verse.GetVerseNumber())
+	}
+}
+
+func TestGRPCNotFound(t *testing.T) {
+	client := newTestGRPCClient(t, newTestDB(t), nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"verse", func() error {
+			_, err := client.GetVerse(ctx, &biblepb.GetVerseRequest{Id: "spa-RVR1960:Gen.1.99"})
+			return err
+		}},
+		{"book", func() error {
+			_, err := client.GetBook(ctx, &biblepb.GetBookRequest{BookId: "spa-RVR1960:Rev"})
+			return err
+		}},
+		{"chapter", func() error {
+			_, err := client.GetChapter(ctx, &biblepb.GetChapterRequest{BookId: "spa-RVR1960:Gen", Chapter: 50})
+			return err
+		}},
+		{"range", func() error {
+			_, err := client.GetRange(ctx, &biblepb.GetRangeRequest{Reference: "Génesis 9:1"})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(tt.call()); code != codes.NotFound {
+				t.Errorf("code = %v, want NotFound", code)
+			}
+		})
+	}
+}
+
+func TestGRPCSearch(t *testing.T) {
+	client := newTestGRPCClient(t, newTestDB(t), nil)
+
+	tests := []struct {
+		query string
+		limit int32
+		want  []string
+	}{
+		{"principio", 0, []string{"spa-RVR1960:Gen.1.1", "spa-RVR1960:John.1.1", "spa-RVR1960:John.1.2"}},
+		{"principio", 2, []string{"spa-RVR1960:Gen.1.1", "spa-RVR1960:John.1.1"}},
+		{"nabucodonosor", 0, []string{"spa-RVR1960:Dan.1.1"}},
+		{"leviatán", 0, []string{}},
+	}
+	for _, tt := range tests {
+		ids, err := searchIds(client, tt.query, tt.limit)
+		if err != nil {
+			t.Errorf("Search(%q, %d): %v", tt.query, tt.limit, err)
+			continue
+		}
+		if len(ids) != len(tt.want) {
+			t.Errorf("Search(%q, %d) = %v, want %v", tt.query, tt.limit, ids, tt.want)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Errorf("Search(%q, %d) = %v, want %v", tt.query, tt.limit, ids, tt.want)
+				break
+			}
+		}
+	}
+
+	if _, err := searchIds(client, "", 0); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("empty query: code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	db := newTestDB(t)
+	cfg := defaultConfig().RateLimit
+	cfg.Search = RateLimit{PerMinute: 1, Burst: 1}
+	client := newTestGRPCClient(t, db, newRateLimiter(cfg, db))
+
+	if _, err := searchIds(client, "principio", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := searchIds(client, "principio", 1); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second search: code = %v, want ResourceExhausted", status.Code(err))
+	}
+	// Reads have their own bucket.
+	if _, err := client.GetVerse(context.Background(), &biblepb.GetVerseRequest{Id: "spa-RVR1960:Gen.1.1"}); err != nil {
+		t.Errorf("GetVerse after the search limit: %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+// commit is the git commit the binary was built from. It can be set with
+// -ldflags "-X main.commit=<sha>"; otherwise the VCS information embedded by
+// `go build` is used.
+var commit = ""
+
+// buildCommit returns the commit the binary was built from, or "unknown".
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		revision, modified := "", false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if revision != "" && modified {
+			return revision + "-dirty"
+		}
+		if revision != "" {
+			return revision
+		}
+	}
+	return "unknown"
+}
+
+// readinessCheck is a named condition that must hold before the server
+// should receive traffic.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+var (
+	readinessMu     sync.RWMutex
+	readinessChecks []readinessCheck
+)
+
+// addReadinessCheck registers a check reported by /readyz. Components that
+// keep data in memory, like the search vocabulary, register a check that
+// fails until it is loaded.
+func addReadinessCheck(name string, check func(ctx context.Context) error) {
+	readinessMu.Lock()
+	defer readinessMu.Unlock()
+	readinessChecks = append(readinessChecks, readinessCheck{name: name, check: check})
+}
+
+type HealthStatus struct {
+	Status string `json:"status" example:"ok" doc:"Estado del proceso"`
+}
+
+type CheckResult struct {
+	Name    string `json:"name" doc:"Nombre de la verificación"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty" doc:"Motivo del fallo, si lo hay"`
+	Latency string `json:"latency" doc:"Duración de la verificación"`
+}
+
+type ReadinessReport struct {
+	Status string        `json:"status" enum:"ready,unavailable"`
+	Checks []CheckResult `json:"checks"`
+}
+
+type ReadinessResponse struct {
+	Status int
+	Body   ReadinessReport
+}
+
+type VersionInfo struct {
+	Version       string        `json:"version" doc:"Versión de la API"`
+	Commit        string        `json:"commit" doc:"Commit de git del binario"`
+	GoVersion     string        `json:"goVersion" doc:"Versión de Go con la que se compiló"`
+	SchemaVersion int           `json:"schemaVersion" doc:"Versión del esquema de Bible.db"`
+	Translations  []Translation `json:"translations" doc:"Traducciones cargadas"`
+}
+
+type Translation struct {
+	ID       string `json:"id" example:"spa-RVR1960"`
+	Name     string `json:"name" example:"Reina-Valera 1960"`
+	Language string `json:"language" example:"es"`
+}
+
+// registerHealthRoutes adds the liveness, readiness and version endpoints and
+// the readiness checks for the database and the search index.
+func registerHealthRoutes(api huma.API, db *sqlx.DB, serverVersion string) {
+	addReadinessCheck("database", func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	})
+	addReadinessCheck("schema_version", func(ctx context.Context) error {
+		version, err := currentSchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		if version != SchemaVersion {
+			return fmt.Errorf("database is at schema version %d, expected %d", version, SchemaVersion)
+		}
+		return nil
+	})
+	addReadinessCheck("sample_verse", func(ctx context.Context) error {
+		verse := Verse{}
+		return dbGet(ctx, db, "sample_verse", &verse, `SELECT id,chapterId,cleanText,reference,"text",chapterNumber,verseNumber FROM verses ORDER BY rowid LIMIT 1`)
+	})
+	addReadinessCheck("search_index", func(ctx context.Context) error {
+		return searchIndexReady(ctx, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "healthz",
+		Method:      http.MethodGet,
+		Path:        "/healthz",
+		Summary:     "Verificar que el proceso está vivo",
+		Description: "Responde siempre con 200 mientras el proceso esté en ejecución. Pensado para las sondas de vida del balanceador de carga.",
+		Tags:        []string{"Health"},
+	}, func(ctx context.Context, input *struct{}) (*SingleResponse[HealthStatus], error) {
+		return &SingleResponse[HealthStatus]{
+			Body: HealthStatus{Status: "ok"},
+		}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "readyz",
+		Method:      http.MethodGet,
+		Path:        "/readyz",
+		Summary:     "Verificar que el servidor está listo para recibir tráfico",
+		Description: "Comprueba que la base de datos está abierta, que su esquema está en la versión esperada, que se puede leer un versículo y que el índice de búsqueda está construido con la configuración actual y su vocabulario cargado. Responde 503 si alguna verificación falla.",
+		Tags:        []string{"Health"},
+		Responses: map[string]*huma.Response{
+			"503": {Description: "Alguna verificación falló"},
+		},
+	}, func(ctx context.Context, input *struct{}) (*ReadinessResponse, error) {
+		readinessMu.RLock()
+		checks := readinessChecks
+		readinessMu.RUnlock()
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		response := &ReadinessResponse{
+			Status: http.StatusOK,
+			Body: ReadinessReport{
+				Status: "ready",
+				Checks: []CheckResult{},
+			},
+		}
+		for _, c := range checks {
+			start := time.Now()
+			err := c.check(ctx)
+			result := CheckResult{
+				Name:    c.name,
+				OK:      err == nil,
+				Latency: time.Since(start).String(),
+			}
+			if err != nil {
+				result.Error = err.Error()
+				response.Status = http.StatusServiceUnavailable
+				response.Body.Status = "unavailable"
+			}
+			response.Body.Checks = append(response.Body.Checks, result)
+		}
+		return response, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-version",
+		Method:      http.MethodGet,
+		Path:        "/api/version",
+		Summary:     "Obtener la versión del servidor",
+		Description: "Devuelve la versión de la API, el commit con el que se compiló el binario, la versión de Go, la versión del esquema de la base de datos y las traducciones cargadas.",
+		Tags:        []string{"Health"},
+	}, func(ctx context.Context, input *struct{}) (*SingleResponse[VersionInfo], error) {
+		version, err := currentSchemaVersion(db)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting schema version from DB: %v", err)
+		}
+		translations := []Translation{}
+		err = dbSelect(ctx, db, "translations", &translations, `SELECT id, name, language FROM translations ORDER BY id`)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting translations from DB: %v", err)
+		}
+		return &SingleResponse[VersionInfo]{
+			Body: VersionInfo{
+				Version:       serverVersion,
+				Commit:        buildCommit(),
+				GoVersion:     runtime.Version(),
+				SchemaVersion: version,
+				Translations:  translations,
+			},
+		}, nil
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// IntegrityViolation describes a single problem found in Bible.db, pointing
+// at the exact book, chapter or verse ID it was found on.
+type IntegrityViolation struct {
+	Check   string `json:"check" doc:"Nombre de la verificación que falló"`
+	ID      string `json:"id" doc:"Identificador del libro, capítulo o versículo afectado"`
+	Message string `json:"message" doc:"Descripción del problema"`
+}
+
+// IntegrityReport is the result of checking every book, chapter and verse in
+// Bible.db for structural consistency.
+type IntegrityReport struct {
+	CheckedAt  time.Time            `json:"checkedAt"`
+	OK         bool                 `json:"ok" doc:"Verdadero si no se encontraron violaciones"`
+	Books      int                  `json:"books"`
+	Chapters   int                  `json:"chapters"`
+	Verses     int                  `json:"verses"`
+	Violations []IntegrityViolation `json:"violations"`
+}
+
+type integrityVerse struct {
+	Verse
+	CleanTextAscii string `db:"cleanTextAscii"`
+}
+
+func (r *IntegrityReport) add(check, id, format string, args ...any) {
+	r.Violations = append(r.Violations, IntegrityViolation{
+		Check:   check,
+		ID:      id,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// checkIntegrity loads the whole text and verifies that chapters and verses
+// are contiguous, that IDs and references are derived consistently from the
+// book and numbers, that cleanTextAscii matches removeAccents(cleanText) and
+// that every chapter's osis_end points at its actual last verse.
+func checkIntegrity(ctx context.Context, db *sqlx.DB) (*IntegrityReport, error) {
+	books := []Book{}
+	chapters := []Chapter{}
+	verses := []integrityVerse{}
+	err := dbSelect(ctx, db, "integrity_books", &books, `SELECT id, name, "order", testament FROM books ORDER BY "order"`)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting books from DB: %v", err)
+	}
+	err = dbSelect(ctx, db, "integrity_chapters", &chapters, `SELECT chapter, id, osis_end FROM chapters ORDER BY id, chapter`)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting chapters from DB: %v", err)
+	}
+	// The verify command runs before migrations, on databases that may not
+	// have cleanTextAscii yet; migration 2 adds and fills it, so there is
+	// nothing to check then.
+	var hasCleanTextAscii bool
+	err = dbGet(ctx, db, "integrity_columns", &hasCleanTextAscii, `SELECT count(*) > 0 FROM pragma_table_info('verses') WHERE name = 'cleanTextAscii'`)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting verse columns from DB: %v", err)
+	}
+	cleanTextAscii := `''`
+	if hasCleanTextAscii {
+		cleanTextAscii = `coalesce(cleanTextAscii, '')`
+	}
+	err = dbSelect(ctx, db, "integrity_verses", &verses, `SELECT id,chapterId,cleanText,reference,"text",chapterNumber,verseNumber,`+cleanTextAscii+` AS cleanTextAscii FROM verses ORDER BY chapterId, verseNumber`)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting verses from DB: %v", err)
+	}
+
+	report := &IntegrityReport{
+		CheckedAt:  time.Now().UTC(),
+		Books:      len(books),
+		Chapters:   len(chapters),
+		Verses:     len(verses),
+		Violations: []IntegrityViolation{},
+	}
+
+	chaptersById := map[string]Chapter{}
+	chaptersByBook := map[string][]Chapter{}
+	for _, chapter := range chapters {
+		chaptersById[chapter.ID] = chapter
+	}
+	versesByChapter := map[string][]integrityVerse{}
+	for _, verse := range verses {
+		versesByChapter[verse.ChapterId] = append(versesByChapter[verse.ChapterId], verse)
+	}
+
+	for _, book := range books {
+		for n := 1; ; n++ {
+			chapter, ok := chaptersById[fmt.Sprintf("%s.%d", book.ID, n)]
+			if !ok {
+				break
+			}
+			chaptersByBook[book.ID] = append(chaptersByBook[book.ID], chapter)
+		}
+		if len(chaptersByBook[book.ID]) == 0 {
+			report.add("book_chapters", book.ID, "book has no chapter 1")
+		}
+	}
+
+	bookNames := map[string]string{}
+	for _, book := range books {
+		bookNames[book.ID] = book.Name
+	}
+	for _, chapter := range chapters {
+		bookId, number, ok := splitChapterId(chapter.ID)
+		if !ok {
+			report.add("chapter_id", chapter.ID, "chapter ID is not of the form <bookId>.<chapter>")
+			continue
+		}
+		if _, ok := bookNames[bookId]; !ok {
+			report.add("chapter_book", chapter.ID, "chapter belongs to unknown book %q", bookId)
+			continue
+		}
+		if number != chapter.Chapter {
+			report.add("chapter_id", chapter.ID, "chapter ID number %d does not match chapter %d", number, chapter.Chapter)
+		}
+		if number > len(chaptersByBook[bookId]) {
+			report.add("book_chapters", chapter.ID, "chapter %d is not contiguous; book %s only has %d contiguous chapters starting at 1", number, bookId, len(chaptersByBook[bookId]))
+		}
+
+		chapterVerses := versesByChapter[chapter.ID]
+		if len(chapterVerses) == 0 {
+			report.add("chapter_verses", chapter.ID, "chapter has no verses")
+			continue
+		}
+		for i, verse := range chapterVerses {
+			if verse.VerseNumber != i+1 {
+				report.add("chapter_verses", verse.ID, "expected verse %d, found verse %d", i+1, verse.VerseNumber)
+				break
+			}
+		}
+		lastVerse := chapterVerses[len(chapterVerses)-1]
+		if chapter.Osis_End != lastVerse.ID {
+			report.add("chapter_osis_end", chapter.ID, "osis_end is %q but the last verse is %q", chapter.Osis_End, lastVerse.ID)
+		}
+	}
+
+	for _, verse := range verses {
+		if _, ok := chaptersById[verse.ChapterId]; !ok {
+			report.add("verse_chapter", verse.ID, "verse belongs to unknown chapter %q", verse.ChapterId)
+			continue
+		}
+		if expected := fmt.Sprintf("%s.%d", verse.ChapterId, verse.VerseNumber); verse.ID != expected {
+			report.add("verse_id", verse.ID, "expected ID %q", expected)
+		}
+		bookId, chapterNumber, _ := splitChapterId(verse.ChapterId)
+		if chapterNumber != verse.ChapterNumber {
+			report.add("verse_chapter_number", verse.ID, "chapterNumber is %d but chapterId is %q", verse.ChapterNumber, verse.ChapterId)
+		}
+		if expected := fmt.Sprintf("%s %d:%d", bookNames[bookId], verse.ChapterNumber, verse.VerseNumber); verse.Reference != expected {
+			report.add("verse_reference", verse.ID, "reference is %q, expected %q", verse.Reference, expected)
+		}
+		if expected := removeAccents(verse.CleanText); hasCleanTextAscii && verse.CleanTextAscii != expected {
+			report.add("verse_clean_text_ascii", verse.ID, "cleanTextAscii does not match removeAccents(cleanText)")
+		}
+	}
+
+	report.OK = len(report.Violations) == 0
+	return report, nil
+}
+
+// splitChapterId splits "spa-RVR1960:Gen.3" into its book ID and chapter
+// number.
+func splitChapterId(chapterId string) (string, int, bool) {
+	for i := len(chapterId) - 1; i >= 0; i-- {
+		if chapterId[i] == '.' {
+			var number int
+			if _, err := fmt.Sscanf(chapterId[i+1:], "%d", &number); err != nil {
+				return "", 0, false
+			}
+			return chapterId[:i], number, true
+		}
+	}
+	return "", 0, false
+}
+
+// runVerify implements the `verify` command: it prints every violation found
+// in Bible.db and returns a non-zero exit code if there are any.
+func runVerify(db *sqlx.DB) int {
+	report, err := checkIntegrity(context.Background(), db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, violation := range report.Violations {
+		fmt.Printf("%s\t%s\t%s\n", violation.Check, violation.ID, violation.Message)
+	}
+	fmt.Printf("Checked %d books, %d chapters and %d verses: %d violations\n", report.Books, report.Chapters, report.Verses, len(report.Violations))
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
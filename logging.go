@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIdHeader is read from incoming requests, so IDs assigned by a proxy
+// or a calling service are kept, and set on every response.
+const requestIdHeader = "X-Request-ID"
+
+// slowQueryThreshold is the duration above which a SQL query is logged as
+// slow. It is set from the configuration at startup; zero disables the log.
+var slowQueryThreshold time.Duration
+
+// setupLogging installs the default slog logger in the configured format and
+// level. The standard log package is routed through it too.
+func setupLogging(cfg LogConfig) {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level))
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(handler))
+	slowQueryThreshold = cfg.SlowQuery
+}
+
+// fatal logs msg at error level and exits the process.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// requestLogger returns the default logger annotated with the request ID and
+// trace ID of ctx, if it has them.
+func requestLogger(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := requestInfoFrom(ctx).RequestID; id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		logger = logger.With("trace_id", spanContext.TraceID().String())
+	}
+	return logger
+}
+
+// validRequestId reports whether a client supplied request ID is safe to
+// echo back and write to the logs.
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withRequestId is chi middleware that assigns every request an ID, taken
+// from the X-Request-ID header when the client sent a valid one, and returns
+// it in the response's X-Request-ID header.
+func withRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIdHeader)
+		if !validRequestId(id) {
+			id = newRequestId()
+		}
+		requestInfoFrom(r.Context()).RequestID = id
+		w.Header().Set(requestIdHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logRequests is chi middleware that writes an access log line for every
+// request once it has been served.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		info := requestInfoFrom(r.Context())
+		requestLogger(r.Context()).LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("operation", info.operationLabel()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.String("remote", r.RemoteAddr),
+			slog.String("api_key", info.APIKeyID),
+		)
+	})
+}
+
+// logSlowQuery logs a query that took longer than slowQueryThreshold.
+func logSlowQuery(ctx context.Context, name string, elapsed time.Duration, query string) {
+	if slowQueryThreshold <= 0 || elapsed < slowQueryThreshold {
+		return
+	}
+	requestLogger(ctx).WarnContext(ctx, "slow query",
+		slog.String("query", name),
+		slog.Duration("duration", elapsed),
+		slog.Duration("threshold", slowQueryThreshold),
+		slog.String("sql", strings.Join(strings.Fields(query), " ")),
+	)
+}
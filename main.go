@@ -3,12 +3,14 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"slices"
-	"strconv"
 	"strings"
 	"unicode"
 
@@ -17,12 +19,17 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/text/runes"
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
 	_ "modernc.org/sqlite"
 )
 
+// apiVersion is the version of the API reported in the OpenAPI document,
+// the health endpoints and the MCP server.
+const apiVersion = "1.0.0"
+
 type Book struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
@@ -37,12 +44,12 @@ type Chapter struct {
 }
 type Verse struct {
 	ID            string `json:"id"`
-	ChapterId     string `json:"chapterId" db:"chapterId"`
-	CleanText     string `json:"cleanText" db:"cleanText"`
-	Reference     string `json:"reference" db:"reference"`
-	Text          string `json:"text" db:"text"`
-	ChapterNumber int    `json:"chapterNumber" db:"chapterNumber"`
-	VerseNumber   int    `json:"verseNumber" db:"verseNumber"`
+	ChapterId     string `json:"chapterId,omitempty" db:"chapterId"`
+	CleanText     string `json:"cleanText,omitempty" db:"cleanText"`
+	Reference     string `json:"reference,omitempty" db:"reference"`
+	Text          string `json:"text,omitempty" db:"text"`
+	ChapterNumber int    `json:"chapterNumber,omitempty" db:"chapterNumber"`
+	VerseNumber   int    `json:"verseNumber,omitempty" db:"verseNumber"`
 }
 type ListResponse[T any] struct {
 	Body []T
@@ -56,21 +63,49 @@ type BookRequest struct {
 
 type VersesByChapterIdRequest struct {
 	BookRequest
+	VerseFieldsRequest
 	ChapterNumber uint `path:"chapterNumber" required:"true" doc:"Número del capítulo del cual obtener los versículos"`
+	CrossRefs     int  `query:"crossrefs" minimum:"0" maximum:"20" default:"0" doc:"Número de referencias cruzadas a incluir en cada versículo, las más votadas; 0 para no incluir ninguna"`
 }
 
 type VerseRequest struct {
 	BookRequest
+	VerseFieldsRequest
 	ChapterNumber uint `path:"chapterNumber" required:"true" doc:"Número del capítulo que contiene el versículo"`
 	VerseNumber   uint `path:"verseNumber" required:"true" doc:"Número del versículo a obtener"`
 }
 
 type SearchRequest struct {
+	VerseFieldsRequest
+	SearchQuery
+}
+
+type SearchQuery struct {
 	Query string `query:"q" required:"true" doc:"texto o termino a buscar"`
+	Mode  string `query:"mode" enum:"exact,accent-insensitive,stemmed,fuzzy" default:"accent-insensitive" doc:"Cómo comparar: exact respeta los acentos, accent-insensitive los ignora (por defecto), stemmed busca todas las palabras, en cualquier orden y con otras terminaciones de la misma raíz (amados, amado, amadas), y fuzzy busca todas las palabras, en cualquier orden, corrigiendo las que no aparecen en el texto (Nabucodonozor, Melquisedek)."`
+}
+
+func (i *SearchQuery) Resolve(ctx huma.Context) []error {
+	return validateProximity(i.Query, i.Mode, "")
+}
+
+type SearchResponse struct {
+	Suggestions string `header:"Search-Suggestions" doc:"Si la búsqueda encontró pocos versículos, búsquedas corregidas que encuentran más, separadas por comas y codificadas para usarse como q="`
+	Body        []Verse
+}
+
+type SearchSuggestionsResponse struct {
+	Body struct {
+		Query       string             `json:"query" doc:"Texto buscado"`
+		Mode        string             `json:"mode" doc:"Modo de búsqueda"`
+		Hits        int                `json:"hits" doc:"Número de versículos que encuentra la búsqueda"`
+		Suggestions []SearchSuggestion `json:"suggestions" doc:"Búsquedas corregidas que encuentran versículos, de la más a la menos probable; vacío si todas las palabras aparecen en el texto"`
+	}
 }
 
 type ChapterToChapterVersesRequest struct {
 	BookRequest
+	VerseFieldsRequest
 	StartChapterNumber uint `path:"startChapterNumber" required:"true" doc:"Capítulo inicial del rango"`
 	EndChapterNumber   uint `path:"endChapterNumber" required:"true" doc:"Capítulo final del rango"`
 	EndVerseNumber     uint `path:"endVerseNumber" required:"true" doc:"Último versículo a incluir del capítulo final"`
@@ -78,6 +113,7 @@ type ChapterToChapterVersesRequest struct {
 
 type VerseRangeRequest struct {
 	BookRequest
+	VerseFieldsRequest
 	StartChapterNumber uint `path:"startChapterNumber" required:"true" doc:"Capítulo inicial"`
 	StartVerseNumber   uint `path:"startVerseNumber" required:"true" doc:"Versículo inicial dentro del capítulo inicial"`
 	EndChapterNumber   uint `path:"endChapterNumber" required:"true" doc:"Capítulo final"`
@@ -86,6 +122,7 @@ type VerseRangeRequest struct {
 
 type ChapterRangeRequest struct {
 	BookRequest
+	VerseFieldsRequest
 	StartChapterNumber uint `path:"startChapterNumber" required:"true" doc:"Capítulo inicial"`
 	EndChapterNumber   uint `path:"endChapterNumber" required:"true" doc:"Capítulo final"`
 }
@@ -147,34 +184,107 @@ func removeAccents(s string) string {
 	// Apply the transformation to the string.
 	output, _, err := transform.String(t, s)
 	if err != nil {
-		// Handle potential errors, e.g., print or return an empty string
-		fmt.Printf("Error transforming string: %v\n", err)
+		// Handle potential errors, e.g., log or return an empty string
+		slog.Warn("error removing accents", "error", err)
 		return s // Or handle error as appropriate for your application
 	}
 	return output
 }
 func main() {
-	// Create a new router & API
-	db, err := sqlx.Open("sqlite", "Bible.db")
+	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("error opening DB")
+		log.Println("No .env file found")
+	}
+	cfg, args, err := loadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
 	}
-	defer db.Close()
-	err = godotenv.Load()
 	if err != nil {
-		log.Println("No .env file found")
+		log.Fatal(err)
 	}
-	port := 8888
-	if os.Getenv("PORT") != "" {
-		port, err = strconv.Atoi(os.Getenv("PORT"))
-		if err != nil {
-			log.Fatal("Error while parsing port")
-		}
+	setupLogging(cfg.Log)
+	setupSearch(cfg.Search)
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "", "verify", "keys", "mcp", "crossrefs":
+	default:
+		fatal("unknown command, expected verify, keys, mcp or crossrefs", "command", command)
+	}
+	// Create a new router & API
+	db, err := sqlx.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)", cfg.DBPath))
+	if err != nil {
+		fatal("error opening DB", "path", cfg.DBPath, "error", err)
+	}
+	if command == "verify" {
+		// Check the data as it is, before migrations backfill or fix any of
+		// it.
+		code := runVerify(db)
+		db.Close()
+		os.Exit(code)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		fatal("error migrating DB", "error", err)
+	}
+	switch command {
+	case "keys":
+		code := runKeys(db, args[1:])
+		db.Close()
+		os.Exit(code)
+	case "crossrefs":
+		code := runCrossRefs(db, args[1:])
+		db.Close()
+		os.Exit(code)
+	}
+	// Only the server and the MCP command search, so only they build the
+	// search index.
+	if err := ensureSearchIndex(context.Background(), db, searchAnalyzer); err != nil {
+		db.Close()
+		fatal("error building search index", "error", err)
+	}
+	if err := loadVocabulary(context.Background(), db); err != nil {
+		db.Close()
+		fatal("error loading search vocabulary", "error", err)
+	}
+	if command == "mcp" {
+		code := runMCP(db, cfg)
+		db.Close()
+		os.Exit(code)
+	}
+	slog.Info("configuration", "config", cfg.String())
+	shutdownTracing, err := setupTracing(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("error setting up tracing", "error", err)
 	}
 
 	router := chi.NewMux()
+	router.Use(withRequestInfo, traceRequests, withRequestId, logRequests)
+	if cfg.FeatureEnabled(FeatureMetrics) {
+		router.Use(recordMetrics)
+	}
+	if cfg.FeatureEnabled(FeatureCORS) {
+		router.Use(newCORS(cfg.CORS).Middleware)
+	}
+	var limiter *rateLimiter
+	if cfg.FeatureEnabled(FeatureRateLimit) {
+		limiter = newRateLimiter(cfg.RateLimit, db)
+		router.Use(limiter.Middleware)
+	}
+	router.Use(cacheImmutable)
+	var compression *compressor
+	if cfg.FeatureEnabled(FeatureCompression) {
+		compression = newCompressor(cfg.Compression, cfg.Cache.MaxEntries)
+		router.Use(compression.Middleware)
+	}
+	if cfg.FeatureEnabled(FeatureMetrics) {
+		router.Handle("/metrics", promhttp.Handler())
+	}
 
-	config := huma.DefaultConfig("RV 1960 API", "1.0.0")
+	config := huma.DefaultConfig("RV 1960 API", apiVersion)
+	config.Components.SecuritySchemes = securitySchemes
 	config.Info.Contact = &huma.Contact{
 		Name:  "Samuel De La Cruz",
 		Email: "delacruzportorrealsamueldavid@gmail.com",
@@ -207,14 +317,11 @@ Esta API proporciona acceso estructurado al texto bíblico de la **Reina-Valera
 Esta API está centrada en la versión **Reina-Valera 1960**.  
 No contiene comentarios, notas teológicas ni versiones alternativas del texto.
 `
-	env := os.Getenv("GO_ENV")
-
-	if env == "PROD" || env == "PRODUCTION" {
-		hostUrl := os.Getenv("HOST_URL")
-		hostPath := "dev"
+	if cfg.IsProduction() {
+		hostUrl := cfg.PublicURL
 		servers := []*huma.Server{
 			{
-				URL:         fmt.Sprintf("%s/%s", hostUrl, hostPath),
+				URL:         cfg.ServerURL(),
 				Description: "API URL",
 			},
 		}
@@ -222,7 +329,7 @@ No contiene comentarios, notas teológicas ni versiones alternativas del texto.
 		config.Servers = servers
 		config.OpenAPI.Servers = []*huma.Server{
 			{
-				URL:         fmt.Sprintf("%s/%s", hostUrl, hostPath),
+				URL:         cfg.ServerURL(),
 				Description: "API URL",
 			},
 		}
@@ -239,74 +346,61 @@ No contiene comentarios, notas teológicas ni versiones alternativas del texto.
 		}
 	}
 	api := humachi.New(router, config)
+	api.UseMiddleware(recordOperation, nameSpan, authorize(api, db, cfg.Auth.AnonymousScopes), markImmutable(compression))
+	registerHealthRoutes(api, db, config.Info.Version)
 
 	huma.Register(api, huma.Operation{
+		OperationID: "list-books",
 		Method:      http.MethodGet,
 		Path:        "/api/books",
 		Summary:     "Obtener todos los libros de la Biblia (RV1960)",
 		Description: "Devuelve la lista completa de libros de la Biblia en la versión Reina Valera 1960, incluyendo información del testamento y los capítulos correspondientes.",
 		Tags:        []string{"Books"},
+		Security:    requireScope(ScopeRead),
 	}, func(ctx context.Context, i *struct{}) (*ListResponse[Book], error) {
-		books := []Book{}
-		chapters := []Chapter{}
-		err := db.Select(&books, `SELECT id, name, "order", testament FROM books ORDER BY "order"`)
+		books, err := listBooks(ctx, db)
 		if err != nil {
 			return nil, fmt.Errorf("error while getting books from DB: %v", err)
 		}
-		err = db.Select(&chapters, "SELECT * FROM chapters")
-		if err != nil {
-			return nil, fmt.Errorf("error while getting chapters from DB: %v", err)
-		}
-
-		for i := range books {
-			bookChapters := Filter(chapters, func(c Chapter) bool {
-				return strings.Contains(c.ID, books[i].ID)
-			})
-			slices.SortFunc(bookChapters, func(c1 Chapter, c2 Chapter) int {
-				return c1.Chapter - c2.Chapter
-			})
-			books[i].Chapters = append(books[i].Chapters, bookChapters...)
-		}
 		return &ListResponse[Book]{
 			Body: books,
 		}, nil
 	})
 
 	huma.Register(api, huma.Operation{
-		Method: http.MethodGet,
+		OperationID: "get-book",
+		Method:      http.MethodGet,
 
 		Path:        "/api/books/{bookId}",
 		Summary:     "Obtener un libro específico (RV1960)",
 		Description: "Devuelve los detalles de un libro de la Biblia en la versión Reina Valera 1960 a partir de su ID, incluyendo los capítulos que lo componen.",
 		Tags:        []string{"Book"},
+		Security:    requireScope(ScopeRead),
 	}, func(ctx context.Context, input *BookRequest) (*SingleResponse[Book], error) {
-		book := Book{}
-
-		err := db.Get(&book, `SELECT id, name, "order", testament FROM books WHERE id = ?`, input.BookId)
+		book, err := getBook(ctx, db, input.BookId)
 		if err != nil {
 			if err != sql.ErrNoRows {
 				return nil, fmt.Errorf("error while getting book from DB: %v", err)
 			}
 			return nil, huma.Error404NotFound(fmt.Sprintf("Book not found: %s", input.BookId))
 		}
-		err = db.Select(&book.Chapters, "SELECT * FROM chapters WHERE id like ? ORDER BY chapter", "%"+book.ID+"%")
-		if err != nil {
-			return nil, fmt.Errorf("error while getting chapters from DB: %v", err)
-		}
 
 		return &SingleResponse[Book]{
 			Body: book,
 		}, nil
 	})
 	huma.Register(api, huma.Operation{
+		OperationID: "get-verses-to-verse",
 		Method:      http.MethodGet,
 		Path:        "/api/books/{bookId}/verses/from/{startChapterNumber}/to/{endChapterNumber}/verse/{endVerseNumber}",
 		Summary:     "Obtener versículos entre capítulos (límite por versículo final)",
 		Description: "Devuelve todos los versículos desde un capítulo inicial hasta un capítulo final, incluyendo solo hasta el versículo especificado en el último capítulo.",
 		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeRead),
 	}, func(ctx context.Context, input *ChapterToChapterVersesRequest) (*ListResponse[Verse], error) {
 		results := []Verse{}
-		err := db.Select(&results, `SELECT id,chapterId,cleanText,reference,"text",chapterNumber,verseNumber FROM verses WHERE chapterId LIKE ? AND chapterNumber between ? AND ?  ORDER BY chapterNumber, verseNumber`, "%"+input.BookId+"%", input.StartChapterNumber, input.EndChapterNumber)
+		fields := input.selection()
+		err := dbSelect(ctx, db, "verses_chapter_to_verse", &results, `SELECT `+fields.columns("chapterNumber", "verseNumber")+` FROM verses WHERE chapterId LIKE ? AND chapterNumber between ? AND ?  ORDER BY chapterNumber, verseNumber`, "%"+input.BookId+"%", input.StartChapterNumber, input.EndChapterNumber)
 		if err != nil {
 			if err != sql.ErrNoRows {
 				return nil, fmt.Errorf("error while getting verses from DB: %v", err)
@@ -317,19 +411,23 @@ No contiene comentarios, notas teológicas ni versiones alternativas del texto.
 			return verse.ChapterNumber == int(input.EndChapterNumber) && verse.VerseNumber == int(input.EndVerseNumber)
 		})
 		results = results[:lastVerseIndex+1]
+		fields.apply(results)
 		return &ListResponse[Verse]{
 			Body: results,
 		}, nil
 	})
 	huma.Register(api, huma.Operation{
+		OperationID: "get-verse-range",
 		Method:      http.MethodGet,
 		Path:        "/api/books/{bookId}/verses/from/{startChapterNumber}/verse/{startVerseNumber}/to/{endChapterNumber}/verse/{endVerseNumber}",
 		Summary:     "Obtener versículos entre capítulo y versículo inicial y final",
 		Description: "Devuelve los versículos que se encuentran entre un capítulo y versículo inicial y un capítulo y versículo final, respetando ambos límites.",
 		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeRead),
 	}, func(ctx context.Context, input *VerseRangeRequest) (*ListResponse[Verse], error) {
 		results := []Verse{}
-		err := db.Select(&results, `SELECT id,chapterId,cleanText,reference,"text",chapterNumber,verseNumber FROM verses WHERE chapterId LIKE ? AND chapterNumber between ? AND ?  ORDER BY chapterNumber, verseNumber`, "%"+input.BookId+"%", input.StartChapterNumber, input.EndChapterNumber)
+		fields := input.selection()
+		err := dbSelect(ctx, db, "verses_range", &results, `SELECT `+fields.columns("chapterNumber", "verseNumber")+` FROM verses WHERE chapterId LIKE ? AND chapterNumber between ? AND ?  ORDER BY chapterNumber, verseNumber`, "%"+input.BookId+"%", input.StartChapterNumber, input.EndChapterNumber)
 		if err != nil {
 			if err != sql.ErrNoRows {
 				return nil, fmt.Errorf("error while getting verses from DB: %v", err)
@@ -343,20 +441,24 @@ No contiene comentarios, notas teológicas ni versiones alternativas del texto.
 			return verse.ChapterNumber == int(input.EndChapterNumber) && verse.VerseNumber == int(input.EndVerseNumber)
 		})
 		results = results[startVerseIndex : lastVerseIndex+1]
+		fields.apply(results)
 		return &ListResponse[Verse]{
 			Body: results,
 		}, nil
 	})
 
 	huma.Register(api, huma.Operation{
+		OperationID: "get-chapter-range",
 		Method:      http.MethodGet,
 		Path:        "/api/books/{bookId}/verses/from/chapter/{startChapterNumber}/to/chapter/{endChapterNumber}",
 		Summary:     "Obtener versículos entre capítulos",
 		Description: "Devuelve todos los versículos que se encuentran entre dos capítulos específicos del mismo libro, sin límite por número de versículo.",
 		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeRead),
 	}, func(ctx context.Context, input *ChapterRangeRequest) (*ListResponse[Verse], error) {
 		results := []Verse{}
-		err := db.Select(&results, `SELECT id,chapterId,cleanText,reference,"text",chapterNumber,verseNumber 
+		fields := input.selection()
+		err := dbSelect(ctx, db, "verses_chapter_range", &results, `SELECT `+fields.columns()+` 
 									FROM verses WHERE chapterId LIKE ? 
 									AND chapterNumber BETWEEN ? AND ? 
 									ORDER BY chapterNumber,verseNumber`, "%"+input.BookId+"%", input.StartChapterNumber, input.EndChapterNumber)
@@ -367,42 +469,50 @@ No contiene comentarios, notas teológicas ni versiones alternativas del texto.
 			return nil, huma.Error404NotFound(fmt.Sprintf("verses not found: %s.%d", input.BookId, input.StartChapterNumber))
 		}
 		results = append(results, results...)
+		fields.apply(results)
 		return &ListResponse[Verse]{
 			Body: results,
 		}, nil
 	})
 
 	huma.Register(api, huma.Operation{
+		OperationID: "get-chapter-verses",
 		Method:      http.MethodGet,
 		Path:        "/api/books/{bookId}/verses/chapter/{chapterNumber}",
 		Summary:     "Obtener versículos por capítulo",
 		Description: "Devuelve todos los versículos de un capítulo específico de un libro de la Biblia en la versión Reina Valera 1960.",
 		Tags:        []string{"Verses"},
-	}, func(ctx context.Context, input *VersesByChapterIdRequest) (*ListResponse[Verse], error) {
-		verses := []Verse{}
-		err := db.Select(&verses, `SELECT id,chapterId,cleanText,reference,"text",chapterNumber,verseNumber FROM verses WHERE chapterId = ? ORDER BY verseNumber`, fmt.Sprintf("%s.%d", input.BookId, input.ChapterNumber))
+		Security:    requireScope(ScopeRead),
+		Metadata:    map[string]any{mutableOperation: "crossrefs"},
+	}, func(ctx context.Context, input *VersesByChapterIdRequest) (*ListResponse[ChapterVerse], error) {
+		verses, err := chapterVerses(ctx, db, fmt.Sprintf("%s.%d", input.BookId, input.ChapterNumber), input.selection())
 		if err != nil {
 			if err != sql.ErrNoRows {
 				return nil, fmt.Errorf("error while getting verses from DB: %v", err)
 			}
 			return nil, huma.Error404NotFound(fmt.Sprintf("verses not found: %s.%d", input.BookId, input.ChapterNumber))
 		}
+		result, err := withCrossReferences(ctx, db, verses, input.CrossRefs, input.selection())
+		if err != nil {
+			return nil, fmt.Errorf("error while getting cross-references from DB: %v", err)
+		}
 
-		return &ListResponse[Verse]{
-			Body: verses,
+		return &ListResponse[ChapterVerse]{
+			Body: result,
 		}, nil
 	})
 
 	huma.Register(api, huma.Operation{
+		OperationID: "get-verse",
 		Method:      http.MethodGet,
 		Path:        "/api/books/{bookId}/verses/chapter/{chapterNumber}/verse/{verseNumber}",
 		Summary:     "Obtener un versículo específico",
 		Description: "Devuelve un versículo específico de un libro a partir del número de capítulo y el número de versículo.",
 		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeRead),
 	}, func(ctx context.Context, input *VerseRequest) (*SingleResponse[Verse], error) {
-		verse := Verse{}
 		verseId := fmt.Sprintf("%s.%d.%d", input.BookId, input.ChapterNumber, input.VerseNumber)
-		err := db.Get(&verse, `SELECT id,chapterId,cleanText,reference,"text",chapterNumber,verseNumber FROM verses WHERE id = ?`, verseId)
+		verse, err := getVerse(ctx, db, verseId, input.selection())
 		if err != nil {
 			if err != sql.ErrNoRows {
 				return nil, fmt.Errorf("error while getting verse from DB: %v", err)
@@ -415,25 +525,94 @@ No contiene comentarios, notas teológicas ni versiones alternativas del texto.
 	})
 
 	huma.Register(api, huma.Operation{
+		OperationID: "search-verses",
 		Method:      http.MethodGet,
 		Path:        "/api/verses/search",
 		Summary:     "Buscar dentro de los versiculos de la biblia",
-		Description: "Devuelve todos los versículos que contengan el texto especificado.",
+		Description: "Devuelve, en orden canónico, todos los versículos que contengan el texto especificado.",
 		Tags:        []string{"Verses"},
-	}, func(ctx context.Context, input *SearchRequest) (*ListResponse[Verse], error) {
-		verses := []Verse{}
-		err := db.Select(&verses, `SELECT id,chapterId,cleanText,reference,"text",chapterNumber,verseNumber FROM verses WHERE cleanTextAscii like ?`, "%"+removeAccents(input.Query)+"%")
+		Security:    requireScope(ScopeSearch),
+	}, func(ctx context.Context, input *SearchRequest) (*SearchResponse, error) {
+		verses, err := searchVerses(ctx, db, input.Query, input.Mode, input.selection())
 		if err != nil {
 			if err != sql.ErrNoRows {
 				return nil, fmt.Errorf("error while getting verses from DB: %v", err)
 			}
 			return nil, huma.Error404NotFound(fmt.Sprintf("verses not found for query: %s", input.Query))
 		}
+		searchResults.WithLabelValues(input.Mode).Observe(float64(len(verses)))
+		suggestions, err := didYouMean(ctx, db, input.Query, input.Mode, len(verses))
+		if err != nil {
+			return nil, fmt.Errorf("error while getting search suggestions from DB: %v", err)
+		}
+		for i, suggestion := range suggestions {
+			suggestions[i] = url.QueryEscape(suggestion)
+		}
 
-		return &ListResponse[Verse]{
-			Body: verses,
+		return &SearchResponse{
+			Suggestions: strings.Join(suggestions, ","),
+			Body:        verses,
 		}, nil
 	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-search-suggestions",
+		Method:      http.MethodGet,
+		Path:        "/api/verses/search/suggestions",
+		Summary:     "Sugerir correcciones para una búsqueda",
+		Description: "Devuelve cuántos versículos encuentra la búsqueda y, si alguna de sus palabras no aparece en el texto, las búsquedas corregidas más probables (\"¿quisiste decir?\") con los versículos que encuentra cada una en el mismo modo.",
+		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeSearch),
+	}, func(ctx context.Context, input *SearchQuery) (*SearchSuggestionsResponse, error) {
+		hits, err := countSearch(ctx, db, input.Query, input.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting verses from DB: %v", err)
+		}
+		suggestions, err := searchSuggestions(ctx, db, input.Query, input.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting search suggestions from DB: %v", err)
+		}
+		response := &SearchSuggestionsResponse{}
+		response.Body.Query = input.Query
+		response.Body.Mode = input.Mode
+		response.Body.Hits = hits
+		response.Body.Suggestions = suggestions
+		return response, nil
+	})
+
+	registerBatchRoutes(api, db)
+	registerProximityRoutes(api, db)
+	registerRegexRoutes(api, db, cfg.Search)
+	registerConcordanceRoutes(api, db)
+	registerStatsRoutes(api, db, cfg.Cache.MaxEntries)
+	registerCrossRefRoutes(api, db)
+	registerStreamRoutes(api, db)
+	if cfg.FeatureEnabled(FeatureGraphQL) {
+		router.Handle("/graphql", newGraphQLHandler(db, cfg))
+	}
+	if cfg.FeatureEnabled(FeatureMCP) {
+		router.Handle("/mcp", newMCPHandler(newMCPServer(db, cfg), db, cfg))
+	}
+
+	if cfg.FeatureEnabled(FeatureAdmin) {
+		huma.Register(api, huma.Operation{
+			OperationID: "get-integrity",
+			Method:      http.MethodGet,
+			Path:        "/api/admin/integrity",
+			Summary:     "Verificar la integridad de la base de datos",
+			Description: "Revisa que los capítulos y versículos sean contiguos, que los identificadores, referencias y osis_end sean consistentes y que cleanTextAscii coincida con cleanText sin acentos. Devuelve cada violación con el identificador exacto.",
+			Tags:        []string{"Admin"},
+			Security:    requireScope(ScopeAdmin),
+		}, func(ctx context.Context, input *struct{}) (*SingleResponse[IntegrityReport], error) {
+			report, err := checkIntegrity(ctx, db)
+			if err != nil {
+				return nil, err
+			}
+			return &SingleResponse[IntegrityReport]{
+				Body: *report,
+			}, nil
+		})
+	}
 	/*
 		huma.Register(api, huma.Operation{
 			Method:      http.MethodGet,
@@ -484,7 +663,22 @@ No contiene comentarios, notas teológicas ni versiones alternativas del texto.
 			}, nil
 		})
 	*/
+	sides := []sideServer{}
+	if cfg.FeatureEnabled(FeatureGRPC) {
+		sides = append(sides, grpcSideServer(newGRPCServer(db, cfg, limiter), cfg))
+	}
+
 	// Start the server!
-	fmt.Printf("Starting server on port %d ", port)
-	http.ListenAndServe(fmt.Sprintf(":%d", port), router)
+	err = runServer(cfg, router, sides...)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	if traceErr := shutdownTracing(shutdownCtx); traceErr != nil {
+		slog.Error("error flushing traces", "error", traceErr)
+	}
+	cancel()
+	if closeErr := db.Close(); closeErr != nil {
+		slog.Error("error closing DB", "error", closeErr)
+	}
+	if err != nil {
+		fatal("server failed", "error", err)
+	}
 }
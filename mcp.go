@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The MCP server exposes the Bible to LLM assistants as tools and resources,
+// so they can quote verses from the database instead of from memory. It is
+// served over streamable HTTP on /mcp with the mcp feature, and over stdio
+// by the mcp command, for assistants that start the binary themselves.
+
+// verseResourceTemplate addresses a verse, or an OSIS range, by its ID.
+const verseResourceTemplate = "bible://verses/{+id}"
+
+// mcpDisplayFields are the verse fields returned to assistants.
+var mcpDisplayFields = verseSelection(verseProjections["display"])
+
+type mcpLookupInput struct {
+	Ref string `json:"ref" jsonschema:"Referencia a buscar: un id de versículo (spa-RVR1960:John.3.16), una referencia OSIS (John.3.16-John.3.18) o una referencia escrita (Juan 3:16-18, Génesis 1, Salmos 1-2)."`
+}
+
+type mcpPassage struct {
+	Passage string  `json:"passage" jsonschema:"Referencia OSIS normalizada del pasaje"`
+	Verses  []Verse `json:"verses"`
+}
+
+type mcpSearchInput struct {
+	Query     string   `json:"query" jsonschema:"Texto a buscar; no distingue acentos ni mayúsculas"`
+	Books     []string `json:"books,omitempty" jsonschema:"Libros donde buscar, por nombre (Juan), abreviatura (John) o id (spa-RVR1960:John); todos si se omite"`
+	Testament string   `json:"testament,omitempty" jsonschema:"OT para el Antiguo Testamento o NT para el Nuevo"`
+	Limit     int      `json:"limit,omitempty" jsonschema:"Máximo de versículos a devolver, 20 por defecto y 100 como máximo"`
+	Mode      string   `json:"mode,omitempty" jsonschema:"exact respeta los acentos, accent-insensitive los ignora (por defecto), stemmed busca todas las palabras en cualquier orden y con otras terminaciones de la misma raíz y fuzzy busca todas las palabras en cualquier orden corrigiendo las mal escritas"`
+}
+
+type mcpSearchResults struct {
+	Query     string  `json:"query"`
+	Truncated bool    `json:"truncated" jsonschema:"true si hay más resultados que limit"`
+	Verses    []Verse `json:"verses"`
+	// Suggestions are the corrected queries returned when few verses were
+	// found.
+	Suggestions []string `json:"suggestions,omitempty" jsonschema:"Búsquedas corregidas que encuentran más versículos, si se encontraron pocos"`
+}
+
+type mcpBook struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Testament string `json:"testament"`
+	Chapters  int    `json:"chapters"`
+}
+
+type mcpBooks struct {
+	Books []mcpBook `json:"books"`
+}
+
+// versesText formats verses one per line, as an assistant would quote them.
+func versesText(verses []Verse) string {
+	lines := make([]string, len(verses))
+	for i, v := range verses {
+		lines[i] = fmt.Sprintf("%s (RVR1960): %s", v.Reference, v.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: text}}}
+}
+
+// lookupPassage resolves a single reference with the batch lookup.
+func lookupPassage(ctx context.Context, db *sqlx.DB, ref string) (BatchItem, error) {
+	results, err := lookupBatch(ctx, db, []string{ref}, mcpDisplayFields)
+	if err != nil {
+		return BatchItem{}, fmt.Errorf("error while getting verses from DB: %v", err)
+	}
+	result := results[ref]
+	if result.Error != nil {
+		return BatchItem{}, errors.New(result.Error.Detail)
+	}
+	return result, nil
+}
+
+// mcpAPIKey is the context key under which newMCPHandler stores the API key
+// sent with an HTTP request, which may be empty.
+type mcpAPIKey struct{}
+
+// mcpAllowed reports whether a tool call may use scope. Calls over stdio
+// come from whoever started the process and are always allowed; calls over
+// HTTP are checked with checkAccess against the key of their request, like
+// the REST API, so they also count against the key's daily quota.
+func mcpAllowed(ctx context.Context, db *sqlx.DB, scope string, anonymousScopes []string) error {
+	key, overHTTP := ctx.Value(mcpAPIKey{}).(string)
+	if !overHTTP {
+		return nil
+	}
+	status, message, err := checkAccess(ctx, db, key, scope, anonymousScopes)
+	if err != nil {
+		requestLogger(ctx).ErrorContext(ctx, message, "error", err)
+	}
+	if status != 0 {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// newMCPServer returns the MCP server with the tools and resources backed by
+// the same queries as the REST API.
+func newMCPServer(db *sqlx.DB, cfg Config) *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: "spanish-bible-api", Title: "Biblia Reina-Valera 1960", Version: apiVersion}, &mcp.ServerOptions{
+		Instructions: "Texto de la Biblia Reina-Valera 1960. Usa lookup_passage para citar versículos exactos en lugar de citarlos de memoria, search_verses para encontrar pasajes por su texto y list_books para conocer los libros y sus capítulos.",
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "lookup_passage",
+		Description: "Devuelve el texto exacto de un versículo o pasaje de la Reina-Valera 1960.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input mcpLookupInput) (*mcp.CallToolResult, mcpPassage, error) {
+		result, err := lookupPassage(ctx, db, input.Ref)
+		if err != nil {
+			return nil, mcpPassage{}, err
+		}
+		return textResult(versesText(result.Verses)), mcpPassage{Passage: result.Passage, Verses: result.Verses}, nil
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "search_verses",
+		Description: "Busca versículos que contengan un texto, opcionalmente solo en algunos libros o en un testamento, en orden canónico.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input mcpSearchInput) (*mcp.CallToolResult, mcpSearchResults, error) {
+		if err := mcpAllowed(ctx, db, ScopeSearch, cfg.Auth.AnonymousScopes); err != nil {
+			return nil, mcpSearchResults{}, err
+		}
+		if err := chargeRateLimit(ctx, routeClassSearch); err != nil {
+			return nil, mcpSearchResults{}, err
+		}
+		if strings.TrimSpace(input.Query) == "" {
+			return nil, mcpSearchResults{}, errors.New("query is required")
+		}
+		mode := input.Mode
+		if mode == "" {
+			mode = SearchAccentInsensitive
+		}
+		if mode != SearchExact && mode != SearchAccentInsensitive && mode != SearchStemmed && mode != SearchFuzzy {
+			return nil, mcpSearchResults{}, fmt.Errorf("mode must be exact, accent-insensitive, stemmed or fuzzy, not %q", input.Mode)
+		}
+		limit := input.Limit
+		if limit <= 0 {
+			limit = 20
+		}
+		limit = min(limit, 100)
+
+		index, err := loadBookIndex(ctx, db)
+		if err != nil {
+			return nil, mcpSearchResults{}, err
+		}
+		bookIds := []string{}
+		for _, name := range input.Books {
+			id, ok := index.lookup(name)
+			if !ok {
+				return nil, mcpSearchResults{}, fmt.Errorf("unknown book %q", name)
+			}
+			bookIds = append(bookIds, id)
+		}
+		if input.Testament != "" {
+			testament := strings.ToUpper(input.Testament)
+			if testament != "OT" && testament != "NT" {
+				return nil, mcpSearchResults{}, fmt.Errorf("testament must be OT or NT, not %q", input.Testament)
+			}
+			books, err := listBooks(ctx, db)
+			if err != nil {
+				return nil, mcpSearchResults{}, fmt.Errorf("error while getting books from DB: %v", err)
+			}
+			inTestament := []string{}
+			for _, book := range books {
+				if book.Testament == testament && (len(bookIds) == 0 || slices.Contains(bookIds, book.ID)) {
+					inTestament = append(inTestament, book.ID)
+				}
+			}
+			if len(inTestament) == 0 {
+				return textResult("No se encontraron versículos."), mcpSearchResults{Query: input.Query, Verses: []Verse{}}, nil
+			}
+			bookIds = inTestament
+		}
+
+		verses, err := searchVersesInBooks(ctx, db, input.Query, mode, bookIds, limit+1, mcpDisplayFields)
+		if err != nil {
+			return nil, mcpSearchResults{}, fmt.Errorf("error while getting verses from DB: %v", err)
+		}
+		results := mcpSearchResults{Query: input.Query, Verses: verses}
+		if len(verses) > limit {
+			results.Verses, results.Truncated = verses[:limit], true
+		}
+		searchResults.WithLabelValues(mode).Observe(float64(len(results.Verses)))
+		results.Suggestions, err = didYouMean(ctx, db, input.Query, mode, len(results.Verses))
+		if err != nil {
+			return nil, mcpSearchResults{}, fmt.Errorf("error while getting search suggestions from DB: %v", err)
+		}
+		text := versesText(results.Verses)
+		if len(results.Verses) == 0 {
+			text = "No se encontraron versículos."
+		}
+		if len(results.Suggestions) > 0 {
+			text += "\n¿Quisiste decir: " + strings.Join(results.Suggestions, ", ") + "?"
+		}
+		return textResult(strings.TrimSpace(text)), results, nil
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_books",
+		Description: "Lista los libros de la Biblia en orden canónico con su id, testamento y número de capítulos.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input struct{}) (*mcp.CallToolResult, mcpBooks, error) {
+		books, err := listBooks(ctx, db)
+		if err != nil {
+			return nil, mcpBooks{}, fmt.Errorf("error while getting books from DB: %v", err)
+		}
+		result := mcpBooks{Books: make([]mcpBook, len(books))}
+		for i, book := range books {
+			result.Books[i] = mcpBook{ID: book.ID, Name: book.Name, Testament: book.Testament, Chapters: len(book.Chapters)}
+		}
+		return nil, result, nil
+	})
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "verse",
+		Title:       "Versículo",
+		Description: "Un versículo por su id, como bible://verses/spa-RVR1960:John.3.16, o un rango OSIS, como bible://verses/spa-RVR1960:John.3.16-John.3.18.",
+		URITemplate: verseResourceTemplate,
+		MIMEType:    "text/plain",
+	}, func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		uri := req.Params.URI
+		id, ok := strings.CutPrefix(uri, "bible://verses/")
+		if !ok {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		result, err := lookupPassage(ctx, db, id)
+		if err != nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{
+			URI:      uri,
+			MIMEType: "text/plain",
+			Text:     versesText(result.Verses),
+		}}}, nil
+	})
+	return server
+}
+
+// newMCPHandler serves server over streamable HTTP. Sessions are stateless,
+// so any instance behind a load balancer can answer any request, and each
+// request needs the read scope like the rest of the API.
+func newMCPHandler(server *mcp.Server, db *sqlx.DB, cfg Config) http.Handler {
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+		return server
+	}, &mcp.StreamableHTTPOptions{Stateless: true})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		requestInfoFrom(ctx).OperationID = "mcp"
+		trace.SpanFromContext(ctx).SetName("mcp")
+
+		key := requestAPIKey(r)
+		status, message, err := checkAccess(ctx, db, key, ScopeRead, cfg.Auth.AnonymousScopes)
+		if err != nil {
+			requestLogger(ctx).ErrorContext(ctx, message, "error", err)
+		}
+		if status != 0 {
+			writeProblem(w, status, message)
+			return
+		}
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(ctx, mcpAPIKey{}, key)))
+	})
+}
+
+// runMCP serves the MCP server over stdin and stdout until the client
+// disconnects or the process is interrupted, and returns the exit code.
+func runMCP(db *sqlx.DB, cfg Config) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := newMCPServer(db, cfg).Run(ctx, &mcp.StdioTransport{})
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
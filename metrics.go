@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bible",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by Huma operation ID and status code.",
+	}, []string{"operation", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "bible",
+		Name:      "http_request_duration_seconds",
+		Help:      "Time spent serving HTTP requests, by Huma operation ID and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "status"})
+
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bible",
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "bible",
+		Name:      "db_query_duration_seconds",
+		Help:      "Time spent running SQLite queries, by query name.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"query"})
+
+	dbQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bible",
+		Name:      "db_query_errors_total",
+		Help:      "SQLite queries that failed, by query name. Queries returning no rows are not errors.",
+	}, []string{"query"})
+
+	searchResults = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "bible",
+		Name:      "search_results",
+		Help:      "Number of verses returned per search, by search mode.",
+		Buckets:   []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+	}, []string{"mode"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bible",
+		Name:      "cache_lookups_total",
+		Help:      "In-memory cache lookups, by cache name and result (hit or miss).",
+	}, []string{"cache", "result"})
+)
+
+// recordCacheLookup counts a lookup in the named cache so its hit ratio can
+// be graphed.
+func recordCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// recordMetrics is chi middleware that counts and times every request, using
+// the Huma operation ID recorded by recordOperation as a label.
+func recordMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{
+			"operation": requestInfoFrom(r.Context()).operationLabel(),
+			"status":    strconv.Itoa(status),
+		}
+		httpRequestsTotal.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
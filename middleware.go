@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// requestInfo carries details about a request, like its ID, the Huma
+// operation it matched and the API key it used, between the chi middleware that wraps the whole
+// request and the Huma layer that only sees part of it.
+type requestInfo struct {
+	RequestID   string
+	OperationID string
+	APIKeyID    string
+	// Scopes are the scopes granted to the request, by its API key or
+	// anonymously.
+	Scopes []string
+	// Immutable is set for operations whose successful responses never
+	// change for a given URL, so they can be cached and pre-compressed.
+	Immutable bool
+}
+
+type requestInfoKey struct{}
+
+// requestInfoFrom returns the requestInfo attached to ctx by
+// withRequestInfo, or an empty one if there is none.
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		return info
+	}
+	return &requestInfo{}
+}
+
+// withRequestInfo is chi middleware that attaches a requestInfo to every
+// request so later middleware can read what the Huma layer filled in.
+func withRequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := context.WithValue(r.Context(), requestInfoKey{}, &requestInfo{})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// recordOperation is Huma middleware that stores the matched operation ID in
+// the request's requestInfo.
+func recordOperation(ctx huma.Context, next func(huma.Context)) {
+	requestInfoFrom(ctx.Context()).OperationID = ctx.Operation().OperationID
+	next(ctx)
+}
+
+// operationLabel is the value used for the operation in metrics and logs,
+// which is "none" for requests that did not match a Huma operation.
+func (info *requestInfo) operationLabel() string {
+	if info.OperationID == "" {
+		return "none"
+	}
+	return info.OperationID
+}
+
+// writeProblem writes an RFC 9457 problem response, like the ones Huma
+// writes, from handlers outside Huma.
+func writeProblem(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"title":  http.StatusText(status),
+		"status": status,
+		"detail": detail,
+	})
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migration is a single, idempotent step in the evolution of the Bible.db
+// schema. Every migration must be safe to run against a database that already
+// contains its changes, since databases built by hand before the
+// schema_version table existed may already have some of them applied.
+type migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sqlx.Tx) error
+}
+
+// migrations is the ordered list of schema changes embedded in this binary.
+// Versions must be contiguous starting at 1; append new migrations at the end
+// and never edit one that has already shipped.
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "baseline books, chapters and verses tables",
+		Up:          migrateBaseline,
+	},
+	{
+		Version:     2,
+		Description: "ensure verses.cleanTextAscii exists and is populated",
+		Up:          migrateCleanTextAscii,
+	},
+	{
+		Version:     3,
+		Description: "add bookId and ordinal columns to chapters and verses",
+		Up:          migrateOrdinals,
+	},
+	{
+		Version:     4,
+		Description: "add lookup indexes for chapters and verses",
+		Up: execStatements(
+			`CREATE INDEX IF NOT EXISTS idx_chapters_bookId ON chapters(bookId, chapter)`,
+			`CREATE INDEX IF NOT EXISTS idx_verses_chapterId ON verses(chapterId, verseNumber)`,
+			`CREATE INDEX IF NOT EXISTS idx_verses_bookId ON verses(bookId, chapterNumber, verseNumber)`,
+			`CREATE INDEX IF NOT EXISTS idx_verses_ordinal ON verses(ordinal)`,
+		),
+	},
+	{
+		Version:     5,
+		Description: "add translations table and books.translationId",
+		Up:          migrateTranslations,
+	},
+	{
+		Version:     6,
+		Description: "add api_keys and api_key_usage tables",
+		Up: execStatements(
+			`CREATE TABLE IF NOT EXISTS api_keys (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				hash TEXT NOT NULL,
+				scopes TEXT NOT NULL,
+				daily_quota INTEGER NOT NULL DEFAULT 0,
+				created_at TEXT NOT NULL,
+				revoked_at TEXT
+			)`,
+			`CREATE TABLE IF NOT EXISTS api_key_usage (
+				key_id TEXT NOT NULL REFERENCES api_keys(id),
+				day TEXT NOT NULL,
+				count INTEGER NOT NULL,
+				PRIMARY KEY (key_id, day)
+			)`,
+		),
+	},
+	{
+		Version:     7,
+		Description: "add verses_analyzed search index, verses_vocabulary and search_index settings",
+		Up: execStatements(
+			`CREATE VIRTUAL TABLE IF NOT EXISTS verses_analyzed USING fts5(
+				lowered UNINDEXED,
+				folded,
+				stems,
+				tokenize='unicode61 remove_diacritics 0'
+			)`,
+			`CREATE VIRTUAL TABLE IF NOT EXISTS verses_vocabulary USING fts5vocab(verses_analyzed, 'col')`,
+			`CREATE TABLE IF NOT EXISTS search_index (
+				analyzer TEXT NOT NULL
+			)`,
+		),
+	},
+	{
+		Version:     8,
+		Description: "add crossrefs table",
+		Up: execStatements(
+			`CREATE TABLE IF NOT EXISTS crossrefs (
+				fromVerseId TEXT NOT NULL,
+				toBookId TEXT NOT NULL,
+				toStartChapter INTEGER NOT NULL,
+				toStartVerse INTEGER NOT NULL,
+				toEndChapter INTEGER NOT NULL,
+				toEndVerse INTEGER NOT NULL,
+				votes INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (fromVerseId, toBookId, toStartChapter, toStartVerse, toEndChapter, toEndVerse)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_crossrefs_votes ON crossrefs(fromVerseId, votes DESC)`,
+		),
+	},
+}
+
+// SchemaVersion is the schema version this binary expects Bible.db to be at
+// once all migrations have been applied.
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+// defaultTranslationId is the translation every book belongs to until the
+// database carries more than one version of the text.
+const defaultTranslationId = "spa-RVR1960"
+
+func execStatements(statements ...string) func(tx *sqlx.Tx) error {
+	return func(tx *sqlx.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func tableExists(tx *sqlx.Tx, table string) (bool, error) {
+	var name string
+	err := tx.Get(&name, `SELECT name FROM sqlite_master WHERE type IN ('table', 'view') AND name = ?`, table)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func columnExists(tx *sqlx.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.Get(&count, `SELECT count(*) FROM pragma_table_info(?) WHERE name = ?`, table, column)
+	return count > 0, err
+}
+
+func addColumnIfMissing(tx *sqlx.Tx, table, column, definition string) error {
+	exists, err := columnExists(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
+func migrateBaseline(tx *sqlx.Tx) error {
+	for _, table := range []string{"books", "chapters", "verses"} {
+		exists, err := tableExists(tx, table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("required table %q is missing; is this a Bible.db file?", table)
+		}
+	}
+	return nil
+}
+
+func migrateCleanTextAscii(tx *sqlx.Tx) error {
+	if err := addColumnIfMissing(tx, "verses", "cleanTextAscii", "TEXT"); err != nil {
+		return err
+	}
+	verses := []Verse{}
+	err := tx.Select(&verses, `SELECT id, cleanText FROM verses WHERE cleanTextAscii IS NULL OR cleanTextAscii = ''`)
+	if err != nil {
+		return err
+	}
+	for _, verse := range verses {
+		if _, err := tx.Exec(`UPDATE verses SET cleanTextAscii = ? WHERE id = ?`, removeAccents(verse.CleanText), verse.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateOrdinals(tx *sqlx.Tx) error {
+	if err := addColumnIfMissing(tx, "chapters", "bookId", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "verses", "bookId", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "verses", "ordinal", "INTEGER"); err != nil {
+		return err
+	}
+	// Chapter and verse IDs are "<bookId>.<chapter>[.<verse>]", and book IDs
+	// never contain a dot, so the book is everything before the first dot.
+	return execStatements(
+		`UPDATE chapters SET bookId = substr(id, 1, instr(id, '.') - 1) WHERE bookId IS NULL`,
+		`UPDATE verses SET bookId = substr(chapterId, 1, instr(chapterId, '.') - 1) WHERE bookId IS NULL`,
+		`UPDATE verses SET ordinal = (
+			SELECT ordered.ordinal FROM (
+				SELECT v.id, row_number() OVER (ORDER BY b."order", v.chapterNumber, v.verseNumber) AS ordinal
+				FROM verses v JOIN books b ON b.id = v.bookId
+			) ordered WHERE ordered.id = verses.id
+		) WHERE ordinal IS NULL`,
+	)(tx)
+}
+
+func migrateTranslations(tx *sqlx.Tx) error {
+	err := execStatements(
+		`CREATE TABLE IF NOT EXISTS translations (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			language TEXT NOT NULL
+		)`,
+		`INSERT OR IGNORE INTO translations (id, name, language) VALUES ('`+defaultTranslationId+`', 'Reina-Valera 1960', 'es')`,
+	)(tx)
+	if err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "books", "translationId", "TEXT"); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE books SET translationId = ? WHERE translationId IS NULL`, defaultTranslationId)
+	return err
+}
+
+// currentSchemaVersion returns the highest migration version recorded in the
+// database, or 0 if it has never been migrated.
+func currentSchemaVersion(db *sqlx.DB) (int, error) {
+	var version sql.NullInt64
+	err := db.Get(&version, `SELECT max(version) FROM schema_version`)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// migrate brings the database up to SchemaVersion, applying each pending
+// migration in its own transaction. It refuses to touch a database whose
+// schema is newer than this binary understands.
+func migrate(db *sqlx.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("error while creating schema_version table: %v", err)
+	}
+	version, err := currentSchemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("error while reading schema version: %v", err)
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d); deploy a newer build or restore an older Bible.db", version, SchemaVersion)
+	}
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		tx, err := db.Beginx()
+		if err != nil {
+			return fmt.Errorf("error while starting migration %d: %v", m.Version, err)
+		}
+		if err := m.Up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error while applying migration %d (%s): %v", m.Version, m.Description, err)
+		}
+		_, err = tx.Exec(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`, m.Version, m.Description, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error while recording migration %d: %v", m.Version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error while committing migration %d: %v", m.Version, err)
+		}
+		slog.Info("applied migration", "version", m.Version, "description", m.Description)
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+// Proximity searches look for several terms together: within a few words of
+// each other with the NEAR/n operator, or anywhere in the same verse, chapter
+// or window of consecutive verses. Terms are words or quoted phrases,
+// analyzed like the accent-insensitive or stemmed search modes and matched
+// against the same full text index.
+
+// Proximity search scopes.
+const (
+	ScopeVerse   = "verse"
+	ScopeChapter = "chapter"
+	ScopeWindow  = "window"
+)
+
+// defaultNear is the distance, in words, of a NEAR without /n, the same as
+// FTS5's.
+const defaultNear = 10
+
+var (
+	queryTokens  = regexp.MustCompile(`"[^"]*"|\S+`)
+	nearOperator = regexp.MustCompile(`^NEAR(?:/(\d+))?$`)
+)
+
+// proximityQuery is a parsed proximity search: the analyzed words of each
+// term, several for a phrase, and the NEAR distance, or -1 if the terms only
+// have to occur together.
+type proximityQuery struct {
+	terms [][]string
+	near  int
+}
+
+// hasNearOperator reports whether query uses NEAR, which is only an operator
+// in uppercase, like in FTS5.
+func hasNearOperator(query string) bool {
+	for _, token := range queryTokens.FindAllString(query, -1) {
+		if nearOperator.MatchString(token) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProximity parses query into terms analyzed for mode, which must be
+// SearchAccentInsensitive or SearchStemmed. NEAR/n must join every term with
+// the same n, as in fe NEAR/5 obras NEAR/5 gracia.
+func parseProximity(query, mode string) (proximityQuery, error) {
+	q := proximityQuery{near: -1}
+	raw := []string{}
+	operators := 0
+	expectTerm := true
+	for _, token := range queryTokens.FindAllString(query, -1) {
+		match := nearOperator.FindStringSubmatch(token)
+		if match == nil {
+			raw = append(raw, strings.Trim(token, `"`))
+			expectTerm = false
+			continue
+		}
+		if expectTerm {
+			return q, errors.New("NEAR needs a term on each side")
+		}
+		near := defaultNear
+		if match[1] != "" {
+			n, err := strconv.Atoi(match[1])
+			if err != nil || n > 100 {
+				return q, fmt.Errorf("%s: the distance must be between 0 and 100 words", token)
+			}
+			near = n
+		}
+		if q.near >= 0 && q.near != near {
+			return q, errors.New("every NEAR in a query must have the same distance")
+		}
+		q.near = near
+		operators++
+		expectTerm = true
+	}
+	if operators > 0 && (expectTerm || operators != len(raw)-1) {
+		return q, errors.New("NEAR must join every term, as in fe NEAR/5 obras NEAR/5 gracia")
+	}
+	for _, term := range raw {
+		var analyzed []string
+		if mode == SearchStemmed {
+			analyzed = searchAnalyzer.stems(term)
+		} else {
+			analyzed = words(searchAnalyzer.fold(term))
+		}
+		if len(analyzed) > 0 {
+			q.terms = append(q.terms, analyzed)
+		}
+	}
+	if len(q.terms) == 0 {
+		return q, errors.New("the query has no words to search for")
+	}
+	if len(q.terms) > maxProximityTerms {
+		return q, fmt.Errorf("the query can have at most %d terms", maxProximityTerms)
+	}
+	return q, nil
+}
+
+// column returns the verses_analyzed column the terms are matched against.
+func (q proximityQuery) column(mode string) string {
+	if mode == SearchStemmed {
+		return "stems"
+	}
+	return "folded"
+}
+
+func phrase(words []string) string {
+	return `"` + strings.Join(words, " ") + `"`
+}
+
+// match returns the FTS5 query for verses with every term, within the NEAR
+// distance if there is one.
+func (q proximityQuery) match(mode string) string {
+	phrases := make([]string, len(q.terms))
+	for i, term := range q.terms {
+		phrases[i] = phrase(term)
+	}
+	if q.near >= 0 {
+		return fmt.Sprintf("%s : NEAR(%s, %d)", q.column(mode), strings.Join(phrases, " "), q.near)
+	}
+	return q.column(mode) + " : (" + strings.Join(phrases, " AND ") + ")"
+}
+
+// validateProximity returns the errors to report for a search request whose
+// query uses NEAR.
+func validateProximity(query, mode, scope string) []error {
+	if !hasNearOperator(query) {
+		return nil
+	}
+	if mode != SearchAccentInsensitive && mode != SearchStemmed {
+		return []error{&huma.ErrorDetail{
+			Location: "query.mode",
+			Message:  "NEAR can only be used in the accent-insensitive and stemmed modes",
+			Value:    mode,
+		}}
+	}
+	if scope != "" && scope != ScopeVerse {
+		return []error{&huma.ErrorDetail{
+			Location: "query.scope",
+			Message:  "NEAR measures words within a verse and can only be used with scope=verse",
+			Value:    scope,
+		}}
+	}
+	if _, err := parseProximity(query, mode); err != nil {
+		return []error{&huma.ErrorDetail{
+			Location: "query.q",
+			Message:  err.Error(),
+			Value:    query,
+		}}
+	}
+	return nil
+}
+
+// SearchSpan is a passage where every term of a proximity search occurs.
+type SearchSpan struct {
+	Passage string  `json:"passage" doc:"Referencia OSIS del pasaje que va del primer al último versículo con alguno de los términos, ej: spa-RVR1960:Jas.2.14-Jas.2.17"`
+	Verses  []Verse `json:"verses" doc:"Los versículos del pasaje que contienen alguno de los términos, en orden"`
+}
+
+type SearchPassagesRequest struct {
+	VerseFieldsRequest
+	Query  string `query:"q" required:"true" doc:"Términos a buscar separados por espacios, entre comillas para buscar una frase (\"fe viva\"), o unidos por NEAR/n para que estén a n palabras o menos entre sí (fe NEAR/5 obras)"`
+	Mode   string `query:"mode" enum:"accent-insensitive,stemmed" default:"accent-insensitive" doc:"Cómo comparar los términos: accent-insensitive ignora mayúsculas y acentos y stemmed acepta otras terminaciones de la misma raíz"`
+	Scope  string `query:"scope" enum:"verse,chapter,window" default:"verse" doc:"Dónde deben aparecer todos los términos: en un mismo versículo, en un mismo capítulo o en window versículos seguidos del mismo libro"`
+	Window int    `query:"window" minimum:"1" maximum:"50" default:"3" doc:"Con scope=window, número de versículos seguidos en que deben aparecer todos los términos"`
+}
+
+func (i *SearchPassagesRequest) Resolve(ctx huma.Context) []error {
+	if hasNearOperator(i.Query) {
+		return validateProximity(i.Query, i.Mode, i.Scope)
+	}
+	if _, err := parseProximity(i.Query, i.Mode); err != nil {
+		return []error{&huma.ErrorDetail{
+			Location: "query.q",
+			Message:  err.Error(),
+			Value:    i.Query,
+		}}
+	}
+	return nil
+}
+
+// termHit is a verse containing some of the terms of a proximity search, as
+// a bit set over the terms.
+type termHit struct {
+	ID            string `db:"id"`
+	BookID        string `db:"bookId"`
+	ChapterID     string `db:"chapterId"`
+	ChapterNumber int    `db:"chapterNumber"`
+	VerseNumber   int    `db:"verseNumber"`
+	Ordinal       int    `db:"ordinal"`
+	terms         uint64
+}
+
+// maxProximityTerms is the number of terms a proximity search may have, so
+// the terms of a verse fit in termHit's bit set.
+const maxProximityTerms = 64
+
+const termHitColumns = `verses.id, verses.bookId, verses.chapterId, verses.chapterNumber, verses.verseNumber, verses.ordinal`
+
+const analyzedJoin = ` JOIN verses_analyzed ON verses_analyzed.rowid = verses.rowid WHERE verses_analyzed MATCH ?`
+
+// proximityHits returns, in canonical order, the verses containing any term
+// of q, each with the terms it contains.
+func proximityHits(ctx context.Context, db *sqlx.DB, q proximityQuery, mode string) ([]termHit, error) {
+	byOrdinal := map[int]*termHit{}
+	for i, term := range q.terms {
+		hits := []termHit{}
+		err := dbSelect(ctx, db, "proximity_term", &hits, `SELECT `+termHitColumns+` FROM verses`+analyzedJoin, q.column(mode)+" : "+phrase(term))
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range hits {
+			if _, ok := byOrdinal[hit.Ordinal]; !ok {
+				byOrdinal[hit.Ordinal] = &hit
+			}
+			byOrdinal[hit.Ordinal].terms |= 1 << i
+		}
+	}
+	result := make([]termHit, 0, len(byOrdinal))
+	for _, hit := range byOrdinal {
+		result = append(result, *hit)
+	}
+	slices.SortFunc(result, func(a, b termHit) int { return a.Ordinal - b.Ordinal })
+	return result, nil
+}
+
+// chapterSpans groups hits by chapter and keeps the chapters with every
+// term.
+func chapterSpans(hits []termHit, all uint64) [][]termHit {
+	spans := [][]termHit{}
+	for start := 0; start < len(hits); {
+		end, terms := start, uint64(0)
+		for ; end < len(hits) && hits[end].ChapterID == hits[start].ChapterID; end++ {
+			terms |= hits[end].terms
+		}
+		if terms == all {
+			spans = append(spans, hits[start:end])
+		}
+		start = end
+	}
+	return spans
+}
+
+// windowSpans returns the passages of hits where every term occurs within
+// window consecutive verses of a book. Overlapping passages are merged into a
+// single span.
+func windowSpans(hits []termHit, all uint64, window int) [][]termHit {
+	spans := [][]termHit{}
+	// For each hit, find the shortest stretch of hits ending there that has
+	// every term and fits in the window. It is only a new passage if it
+	// starts later than the last one; otherwise it contains it.
+	counts := map[int]int{}
+	left, lastLeft := 0, -1
+	lastStart, lastEnd := -1, -1
+	add := func(hit termHit, delta int) {
+		for bit := range maxProximityTerms {
+			if hit.terms&(1<<bit) != 0 {
+				counts[bit] += delta
+			}
+		}
+	}
+	covered := func() bool {
+		for bit := range maxProximityTerms {
+			if all&(1<<bit) != 0 && counts[bit] == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	flush := func() {
+		if lastStart >= 0 {
+			spans = append(spans, hits[lastStart:lastEnd+1])
+		}
+		lastStart, lastEnd = -1, -1
+	}
+	for right, hit := range hits {
+		if hit.BookID != hits[left].BookID {
+			flush()
+			clear(counts)
+			left = right
+		}
+		add(hit, 1)
+		for left < right && hits[right].Ordinal-hits[left].Ordinal >= window {
+			add(hits[left], -1)
+			left++
+		}
+		if !covered() {
+			continue
+		}
+		for left < right {
+			add(hits[left], -1)
+			if !covered() {
+				add(hits[left], 1)
+				break
+			}
+			left++
+		}
+		if left <= lastLeft {
+			continue
+		}
+		lastLeft = left
+		if lastStart >= 0 && left <= lastEnd {
+			lastEnd = right
+			continue
+		}
+		flush()
+		lastStart, lastEnd = left, right
+	}
+	flush()
+	return spans
+}
+
+// searchSpans returns the passages where every term of the query occurs in
+// scope, in canonical order, with the verses that contain them.
+func searchSpans(ctx context.Context, db *sqlx.DB, query, mode, scope string, window int, fields verseSelection) ([]SearchSpan, error) {
+	q, err := parseProximity(query, mode)
+	if err != nil {
+		return nil, err
+	}
+	all := uint64(1)<<len(q.terms) - 1
+
+	var groups [][]termHit
+	if scope == ScopeVerse {
+		hits := []termHit{}
+		err := dbSelect(ctx, db, "proximity_verses", &hits, `SELECT `+termHitColumns+` FROM verses`+analyzedJoin+` ORDER BY verses.ordinal`, q.match(mode))
+		if err != nil {
+			return nil, err
+		}
+		for i := range hits {
+			groups = append(groups, hits[i:i+1])
+		}
+	} else {
+		hits, err := proximityHits(ctx, db, q, mode)
+		if err != nil {
+			return nil, err
+		}
+		if scope == ScopeChapter {
+			groups = chapterSpans(hits, all)
+		} else {
+			groups = windowSpans(hits, all, window)
+		}
+	}
+
+	ids := []string{}
+	for _, group := range groups {
+		for _, hit := range group {
+			ids = append(ids, hit.ID)
+		}
+	}
+	verses := map[string]Verse{}
+	if len(ids) > 0 {
+		found := []Verse{}
+		if err := selectIn(ctx, db, "proximity_span_verses", &found, `SELECT `+fields.columns()+` FROM verses WHERE id IN (?)`, ids); err != nil {
+			return nil, err
+		}
+		fields.apply(found)
+		for _, v := range found {
+			verses[v.ID] = v
+		}
+	}
+
+	spans := make([]SearchSpan, len(groups))
+	for i, group := range groups {
+		first, last := group[0], group[len(group)-1]
+		spans[i].Passage = passage{
+			BookID:       first.BookID,
+			StartChapter: first.ChapterNumber,
+			StartVerse:   first.VerseNumber,
+			EndChapter:   last.ChapterNumber,
+			EndVerse:     last.VerseNumber,
+		}.String()
+		spans[i].Verses = make([]Verse, len(group))
+		for j, hit := range group {
+			spans[i].Verses[j] = verses[hit.ID]
+		}
+	}
+	return spans, nil
+}
+
+func registerProximityRoutes(api huma.API, db *sqlx.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "search-passages",
+		Method:      http.MethodGet,
+		Path:        "/api/verses/search/passages",
+		Summary:     "Buscar pasajes donde aparecen varios términos juntos",
+		Description: "Devuelve, en orden canónico, los pasajes donde aparecen todos los términos: a n palabras o menos entre sí con NEAR/n, o en un mismo versículo, capítulo o ventana de versículos seguidos según scope. Cada pasaje va del primer al último versículo con alguno de los términos e incluye esos versículos.",
+		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeSearch),
+	}, func(ctx context.Context, input *SearchPassagesRequest) (*ListResponse[SearchSpan], error) {
+		spans, err := searchSpans(ctx, db, input.Query, input.Mode, input.Scope, input.Window, input.selection())
+		if err != nil {
+			return nil, fmt.Errorf("error while getting verses from DB: %v", err)
+		}
+		return &ListResponse[SearchSpan]{
+			Body: spans,
+		}, nil
+	})
+}
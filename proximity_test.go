@@ -0,0 +1,152 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProximity(t *testing.T) {
+	tests := []struct {
+		query, mode string
+		terms       [][]string
+		near        int
+	}{
+		{"fe obras", SearchAccentInsensitive, [][]string{{"fe"}, {"obras"}}, -1},
+		{"fe NEAR obras", SearchAccentInsensitive, [][]string{{"fe"}, {"obras"}}, defaultNear},
+		{"fe NEAR/5 obras NEAR/5 gracia", SearchAccentInsensitive, [][]string{{"fe"}, {"obras"}, {"gracia"}}, 5},
+		{"fe NEAR/0 obras", SearchAccentInsensitive, [][]string{{"fe"}, {"obras"}}, 0},
+		{`"el Verbo" NEAR/3 Dios`, SearchAccentInsensitive, [][]string{{"el", "verbo"}, {"dios"}}, 3},
+		{"Espíritu NEAR/2 Jehová", SearchAccentInsensitive, [][]string{{"espiritu"}, {"jehova"}}, 2},
+		// NEAR is only an operator in uppercase.
+		{"fe near obras", SearchAccentInsensitive, [][]string{{"fe"}, {"near"}, {"obras"}}, -1},
+		// Stopwords are dropped from stemmed terms.
+		{`"el amor" NEAR/4 Dios`, SearchStemmed, [][]string{{"amor"}, {"dios"}}, 4},
+	}
+	for _, tt := range tests {
+		q, err := parseProximity(tt.query, tt.mode)
+		if err != nil {
+			t.Errorf("parseProximity(%q, %s): %v", tt.query, tt.mode, err)
+			continue
+		}
+		if !reflect.DeepEqual(q.terms, tt.terms) || q.near != tt.near {
+			t.Errorf("parseProximity(%q, %s) = %v NEAR %d, want %v NEAR %d", tt.query, tt.mode, q.terms, q.near, tt.terms, tt.near)
+		}
+	}
+}
+
+func TestParseProximityErrors(t *testing.T) {
+	tests := []struct {
+		query, mode string
+	}{
+		{"NEAR fe", SearchAccentInsensitive},
+		{"fe NEAR", SearchAccentInsensitive},
+		{"fe NEAR NEAR obras", SearchAccentInsensitive},
+		{"fe NEAR/5 obras NEAR/3 gracia", SearchAccentInsensitive},
+		{"fe obras NEAR/5 gracia", SearchAccentInsensitive},
+		{"fe NEAR/101 obras", SearchAccentInsensitive},
+		{"... ¡!", SearchAccentInsensitive},
+		{"de la", SearchStemmed},
+	}
+	for _, tt := range tests {
+		if q, err := parseProximity(tt.query, tt.mode); err == nil {
+			t.Errorf("parseProximity(%q, %s) = %v NEAR %d, want an error", tt.query, tt.mode, q.terms, q.near)
+		}
+	}
+}
+
+// hit returns a termHit for verse ordinal of book with the given terms.
+func hit(book string, ordinal int, terms uint64) termHit {
+	return termHit{BookID: book, Ordinal: ordinal, terms: terms}
+}
+
+func TestWindowSpans(t *testing.T) {
+	const a, b, both = 1, 2, 3
+	tests := []struct {
+		name   string
+		hits   []termHit
+		window int
+		want   [][]int
+	}{
+		{
+			name:   "adjacent verses",
+			hits:   []termHit{hit("Gen", 1, a), hit("Gen", 2, b)},
+			window: 3,
+			want:   [][]int{{1, 2}},
+		},
+		{
+			name:   "one verse with every term",
+			hits:   []termHit{hit("Gen", 5, both)},
+			window: 1,
+			want:   [][]int{{5}},
+		},
+		{
+			name:   "too far apart",
+			hits:   []termHit{hit("Gen", 1, a), hit("Gen", 4, b)},
+			window: 3,
+			want:   [][]int{},
+		},
+		{
+			name:   "just inside the window",
+			hits:   []termHit{hit("Gen", 1, a), hit("Gen", 3, b)},
+			window: 3,
+			want:   [][]int{{1, 3}},
+		},
+		{
+			name:   "overlapping passages are merged",
+			hits:   []termHit{hit("Gen", 1, a), hit("Gen", 2, b), hit("Gen", 3, a), hit("Gen", 4, b)},
+			window: 2,
+			want:   [][]int{{1, 2, 3, 4}},
+		},
+		{
+			name:   "separate passages",
+			hits:   []termHit{hit("Gen", 1, a), hit("Gen", 2, b), hit("Gen", 20, b), hit("Gen", 21, a)},
+			window: 3,
+			want:   [][]int{{1, 2}, {20, 21}},
+		},
+		{
+			name:   "the shortest passage is kept",
+			hits:   []termHit{hit("Gen", 1, a), hit("Gen", 2, a), hit("Gen", 3, b)},
+			window: 5,
+			want:   [][]int{{2, 3}},
+		},
+		{
+			name:   "no passage across a book boundary",
+			hits:   []termHit{hit("Gen", 50, a), hit("Exod", 51, b)},
+			window: 5,
+			want:   [][]int{},
+		},
+		{
+			name: "passages on both sides of a book boundary are not merged",
+			hits: []termHit{
+				hit("Gen", 49, a), hit("Gen", 50, b),
+				hit("Exod", 51, a), hit("Exod", 52, b),
+			},
+			window: 5,
+			want:   [][]int{{49, 50}, {51, 52}},
+		},
+		{
+			name: "hits of the previous book are forgotten",
+			hits: []termHit{
+				hit("Gen", 49, a), hit("Gen", 50, a),
+				hit("Exod", 51, b), hit("Exod", 53, a),
+			},
+			window: 5,
+			want:   [][]int{{51, 53}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := [][]int{}
+			for _, span := range windowSpans(tt.hits, both, tt.window) {
+				ordinals := []int{}
+				for _, h := range span {
+					ordinals = append(ordinals, h.Ordinal)
+				}
+				got = append(got, ordinals)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("windowSpans = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
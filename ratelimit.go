@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Route classes are rate limited separately so a client scraping search
+// results cannot use up the budget for cheap single-verse reads, and vice
+// versa.
+const (
+	routeClassRead   = "read"
+	routeClassSearch = "search"
+	routeClassExport = "export"
+)
+
+// routeClass returns the rate limit class of a request path, or "" if the
+// path is not rate limited at all, like the health checks and metrics.
+// GraphQL and MCP requests are reads; the searches they run are also charged
+// to the search class with chargeRateLimit.
+func routeClass(path string) string {
+	switch {
+	case path == "/graphql", path == "/mcp":
+		return routeClassRead
+	case !strings.HasPrefix(path, "/api/"):
+		return ""
+	case strings.HasPrefix(path, "/api/verses/search"), strings.HasPrefix(path, "/api/concordance"):
+		return routeClassSearch
+	case strings.Contains(path, "/verses/from/"), strings.HasPrefix(path, "/api/verses/batch"):
+		return routeClassExport
+	default:
+		return routeClassRead
+	}
+}
+
+// RateLimit is a token bucket: Burst requests can be made at once, and the
+// bucket refills at PerMinute requests per minute.
+type RateLimit struct {
+	PerMinute float64 `yaml:"perMinute" toml:"perMinute"`
+	Burst     int     `yaml:"burst" toml:"burst"`
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%g:%d", l.PerMinute, l.Burst)
+}
+
+// rateLimitValue is a flag.Value for limits written as PER_MINUTE:BURST,
+// e.g. "60:20". A bare number uses it as both the rate and the burst.
+type rateLimitValue struct {
+	limit *RateLimit
+}
+
+func (v rateLimitValue) String() string {
+	if v.limit == nil {
+		return ""
+	}
+	return v.limit.String()
+}
+
+func (v rateLimitValue) Set(s string) error {
+	perMinute, burst, hasBurst := strings.Cut(s, ":")
+	rate, err := strconv.ParseFloat(perMinute, 64)
+	if err != nil {
+		return fmt.Errorf("expected PER_MINUTE:BURST, e.g. 60:20")
+	}
+	v.limit.PerMinute = rate
+	v.limit.Burst = int(math.Ceil(rate))
+	if hasBurst {
+		if v.limit.Burst, err = strconv.Atoi(burst); err != nil {
+			return fmt.Errorf("expected PER_MINUTE:BURST, e.g. 60:20")
+		}
+	}
+	return nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps one token bucket per client and route class in memory.
+// It is meant for a single instance; each instance behind a load balancer
+// enforces its own limits.
+type rateLimiter struct {
+	cfg     RateLimitConfig
+	trusted []netip.Prefix
+	db      *sqlx.DB
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	keysMu sync.Mutex
+	keys   map[string]cachedKey
+}
+
+// cachedKey remembers which API key ID a key hash belongs to, so verifying
+// the key used as the rate limit identity does not cost a query per request.
+type cachedKey struct {
+	id      string
+	expires time.Time
+}
+
+func newRateLimiter(cfg RateLimitConfig, db *sqlx.DB) *rateLimiter {
+	trusted := []netip.Prefix{}
+	for _, cidr := range cfg.TrustedProxies {
+		// Validate has already checked every entry parses.
+		if prefix, err := netip.ParsePrefix(cidr); err == nil {
+			trusted = append(trusted, prefix)
+		}
+	}
+	return &rateLimiter{
+		cfg:     cfg,
+		trusted: trusted,
+		db:      db,
+		buckets: map[string]*bucket{},
+		keys:    map[string]cachedKey{},
+	}
+}
+
+func (rl *rateLimiter) limitFor(class string, withKey bool) RateLimit {
+	var limit RateLimit
+	switch class {
+	case routeClassSearch:
+		limit = rl.cfg.Search
+	case routeClassExport:
+		limit = rl.cfg.Export
+	default:
+		limit = rl.cfg.Read
+	}
+	if withKey {
+		limit.PerMinute *= rl.cfg.KeyFactor
+		limit.Burst = int(math.Ceil(float64(limit.Burst) * rl.cfg.KeyFactor))
+	}
+	return limit
+}
+
+// isTrusted reports whether addr is a proxy whose forwarding headers can be
+// believed.
+func (rl *rateLimiter) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range rl.trusted {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client that made the request. When the
+// request comes from a trusted proxy, X-Forwarded-For is walked from the
+// right, skipping further trusted proxies, so clients cannot spoof it.
+func (rl *rateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !rl.isTrusted(addr) {
+		return host
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		addr = hop
+		if !rl.isTrusted(hop) {
+			break
+		}
+	}
+	return addr.Unmap().String()
+}
+
+// apiKeyID returns the ID of key if it is a valid API key, or "" if it is
+// not. Unknown keys are not used as an identity, otherwise a scraper could
+// get a fresh bucket for every made-up key.
+func (rl *rateLimiter) apiKeyID(ctx context.Context, key string) string {
+	if key == "" {
+		return ""
+	}
+	hash := hashAPIKey(key)
+	now := time.Now()
+	rl.keysMu.Lock()
+	cached, ok := rl.keys[hash]
+	rl.keysMu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.id
+	}
+	id := ""
+	if apiKey, err := lookupAPIKey(ctx, rl.db, key); err == nil {
+		id = apiKey.ID
+	}
+	rl.keysMu.Lock()
+	if len(rl.keys) > 10000 {
+		clear(rl.keys)
+	}
+	rl.keys[hash] = cachedKey{id: id, expires: now.Add(time.Minute)}
+	rl.keysMu.Unlock()
+	return id
+}
+
+// take removes a token from the bucket for id, returning whether the request
+// is allowed, the tokens left and how long until the next token is added.
+func (rl *rateLimiter) take(id string, limit RateLimit, now time.Time) (bool, int, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// Buckets idle long enough to have refilled are the same as new ones,
+	// so they can be dropped.
+	if now.Sub(rl.lastSweep) > time.Minute {
+		for key, b := range rl.buckets {
+			if now.Sub(b.last) > 10*time.Minute {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	perSecond := limit.PerMinute / 60
+	b, ok := rl.buckets[id]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		rl.buckets[id] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+		return false, 0, wait
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// rateLimitResult is the outcome of taking a token for a request.
+type rateLimitResult struct {
+	class     string
+	limit     RateLimit
+	allowed   bool
+	remaining int
+	wait      time.Duration
+}
+
+// limited reports whether the class has a limit at all.
+func (r rateLimitResult) limited() bool {
+	return r.limit.PerMinute > 0 && r.limit.Burst > 0
+}
+
+func (r rateLimitResult) retryAfter() int {
+	return int(math.Ceil(r.wait.Seconds()))
+}
+
+func (r rateLimitResult) Error() string {
+	return fmt.Sprintf("rate limit of %g %s requests per minute exceeded, retry in %d seconds", r.limit.PerMinute, r.class, r.retryAfter())
+}
+
+// check takes a token from the bucket of identity for class. Classes without
+// a limit always allow.
+func (rl *rateLimiter) check(identity, class string, withKey bool) rateLimitResult {
+	result := rateLimitResult{class: class, limit: rl.limitFor(class, withKey), allowed: true}
+	if !result.limited() {
+		return result
+	}
+	result.allowed, result.remaining, result.wait = rl.take(identity+"|"+class, result.limit, time.Now())
+	return result
+}
+
+// rateLimitKey is the context key of the rateLimitCharge of a request.
+type rateLimitKey struct{}
+
+// rateLimitCharge takes a token from the bucket of class for the client of
+// a request, returning an error if the bucket is empty.
+type rateLimitCharge func(class string) error
+
+// chargeRateLimit charges an operation to the bucket of class of the client
+// that made the request of ctx, for operations served by an endpoint of
+// another class, like searches made through GraphQL or MCP. It allows
+// everything when rate limiting is disabled.
+func chargeRateLimit(ctx context.Context, class string) error {
+	if charge, ok := ctx.Value(rateLimitKey{}).(rateLimitCharge); ok {
+		return charge(class)
+	}
+	return nil
+}
+
+// Middleware is chi middleware that enforces the limits, keyed by API key
+// when a valid one is sent and by client IP otherwise, and reports the state
+// of the bucket in RateLimit-* headers.
+func (rl *rateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := routeClass(r.URL.Path)
+		if class == "" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		identity := "ip:" + rl.clientIP(r)
+		keyID := rl.apiKeyID(r.Context(), requestAPIKey(r))
+		if keyID != "" {
+			identity = "key:" + keyID
+		}
+		r = r.WithContext(context.WithValue(r.Context(), rateLimitKey{}, rateLimitCharge(func(class string) error {
+			if result := rl.check(identity, class, keyID != ""); !result.allowed {
+				return result
+			}
+			return nil
+		})))
+		result := rl.check(identity, class, keyID != "")
+		if !result.limited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := result.limit
+		refill := time.Duration(float64(limit.Burst-result.remaining) / (limit.PerMinute / 60) * float64(time.Second))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(refill.Seconds()))))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, int(math.Ceil(float64(limit.Burst)/limit.PerMinute*60))))
+		if result.allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(result.retryAfter()))
+		writeProblem(w, http.StatusTooManyRequests, result.Error())
+	})
+}
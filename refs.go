@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// passage is a contiguous run of verses within one book. A zero StartVerse
+// means the passage starts at the beginning of StartChapter, and a zero
+// EndVerse that it runs to the end of EndChapter.
+type passage struct {
+	BookID       string
+	StartChapter int
+	StartVerse   int
+	EndChapter   int
+	EndVerse     int
+}
+
+// String returns the passage as an OSIS reference, like
+// spa-RVR1960:Gen.1.1-Gen.1.3.
+func (p passage) String() string {
+	ref := fmt.Sprintf("%s.%d", p.BookID, p.StartChapter)
+	if p.StartVerse > 0 {
+		ref += fmt.Sprintf(".%d", p.StartVerse)
+	}
+	if p.StartChapter == p.EndChapter && p.StartVerse == p.EndVerse {
+		return ref
+	}
+	_, abbreviation, _ := strings.Cut(p.BookID, ":")
+	ref += fmt.Sprintf("-%s.%d", abbreviation, p.EndChapter)
+	if p.EndVerse > 0 {
+		ref += fmt.Sprintf(".%d", p.EndVerse)
+	}
+	return ref
+}
+
+// bounds returns the first and last chapterNumber*1000+verseNumber keys of
+// the passage, for range queries over verses.
+func (p passage) bounds() (int, int) {
+	endVerse := p.EndVerse
+	if endVerse == 0 {
+		endVerse = 999
+	}
+	return p.StartChapter*1000 + p.StartVerse, p.EndChapter*1000 + endVerse
+}
+
+// contains reports whether the verse falls within the passage.
+func (p passage) contains(v Verse) bool {
+	first, last := p.bounds()
+	key := v.ChapterNumber*1000 + v.VerseNumber
+	return key >= first && key <= last
+}
+
+// bookIndex resolves the ways a book can be named in a reference to its ID:
+// the ID itself, its OSIS abbreviation, its Spanish name with or without
+// accents, and unambiguous prefixes of the name such as "Gén" or "Apoc".
+type bookIndex struct {
+	ids   map[string]string
+	names map[string]string
+}
+
+// bookKey normalizes a book name for lookups, so "1 Juan", "1juan" and
+// "1 JUAN." are the same book.
+func bookKey(name string) string {
+	name = strings.ToLower(removeAccents(name))
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '.' {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+func newBookIndex(books []Book) *bookIndex {
+	index := &bookIndex{ids: map[string]string{}, names: map[string]string{}}
+	for _, book := range books {
+		_, abbreviation, _ := strings.Cut(book.ID, ":")
+		index.ids[bookKey(book.ID)] = book.ID
+		index.ids[bookKey(abbreviation)] = book.ID
+		index.names[bookKey(book.Name)] = book.ID
+	}
+	return index
+}
+
+// lookup returns the ID of the named book.
+func (index *bookIndex) lookup(name string) (string, bool) {
+	key := bookKey(name)
+	if id, ok := index.ids[key]; ok {
+		return id, true
+	}
+	if id, ok := index.names[key]; ok {
+		return id, true
+	}
+	if len(key) < 3 {
+		return "", false
+	}
+	match := ""
+	for name, id := range index.names {
+		if strings.HasPrefix(name, key) {
+			if match != "" && match != id {
+				return "", false
+			}
+			match = id
+		}
+	}
+	return match, match != ""
+}
+
+var (
+	booksMu     sync.Mutex
+	cachedBooks *bookIndex
+)
+
+// loadBookIndex returns the index of the books in the database. Books never
+// change while the server runs, so it is read once.
+func loadBookIndex(ctx context.Context, db *sqlx.DB) (*bookIndex, error) {
+	booksMu.Lock()
+	defer booksMu.Unlock()
+	if cachedBooks != nil {
+		return cachedBooks, nil
+	}
+	books := []Book{}
+	if err := dbSelect(ctx, db, "books", &books, `SELECT id, name, "order", testament FROM books ORDER BY "order"`); err != nil {
+		return nil, fmt.Errorf("error while getting books from DB: %v", err)
+	}
+	cachedBooks = newBookIndex(books)
+	return cachedBooks, nil
+}
+
+var (
+	// osisReference matches verse IDs and OSIS references such as
+	// spa-RVR1960:Gen.1.1, Gen.1, Gen.1.1-Gen.1.3, Gen.1.1-Gen.2 or
+	// Gen.1.1-2.3. The end book, if any, is captured apart from the numbers.
+	osisReference = regexp.MustCompile(`^((?:[\w-]+:)?[1-3]?[A-Za-z]+)\.(\d+)(?:\.(\d+))?(?:-(?:((?:[\w-]+:)?[1-3]?[A-Za-z]+)\.)?(\d+)(?:\.(\d+))?)?$`)
+	// humanReference matches references as people write them, such as
+	// Juan 3:16, 1 Juan 1:1-4, Génesis 1:1-2:3 or Salmos 1-2.
+	humanReference = regexp.MustCompile(`^(.+?)\s*(\d+)(?:[:.,](\d+))?(?:\s*[-–]\s*(\d+)(?:[:.,](\d+))?)?$`)
+)
+
+// parseReference parses a verse ID, an OSIS reference or a written reference
+// into a passage.
+func parseReference(ref string, books *bookIndex) (passage, error) {
+	ref = strings.TrimSpace(ref)
+	endBook := ""
+	match := osisReference.FindStringSubmatch(ref)
+	if match != nil {
+		// Drop the end book so the numbers line up with humanReference.
+		endBook = match[4]
+		match = append(match[:4], match[5:]...)
+	} else {
+		match = humanReference.FindStringSubmatch(ref)
+	}
+	if match == nil {
+		return passage{}, fmt.Errorf("cannot parse reference %q, expected a verse ID like spa-RVR1960:Gen.1.1 or a reference like Génesis 1:1-3", ref)
+	}
+	bookID, ok := books.lookup(match[1])
+	if !ok {
+		return passage{}, fmt.Errorf("unknown book %q in reference %q", strings.TrimSpace(match[1]), ref)
+	}
+	if endBook != "" {
+		endBookID, ok := books.lookup(endBook)
+		if !ok {
+			return passage{}, fmt.Errorf("unknown book %q in reference %q", endBook, ref)
+		}
+		if endBookID != bookID {
+			return passage{}, fmt.Errorf("reference %q spans more than one book", ref)
+		}
+	}
+	numbers := make([]int, 4)
+	for i, s := range match[2:] {
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 999 {
+			return passage{}, fmt.Errorf("invalid chapter or verse number %q in reference %q", s, ref)
+		}
+		numbers[i] = n
+	}
+	p := passage{BookID: bookID, StartChapter: numbers[0], StartVerse: numbers[1]}
+	switch {
+	case numbers[2] == 0:
+		// A single chapter or verse.
+		p.EndChapter, p.EndVerse = p.StartChapter, p.StartVerse
+	case numbers[3] != 0:
+		// Chapter and verse at both ends.
+		p.EndChapter, p.EndVerse = numbers[2], numbers[3]
+	case endBook != "":
+		// A single number after the end book is a chapter, as in
+		// Gen.1.1-Gen.2.
+		p.EndChapter = numbers[2]
+	case p.StartVerse != 0:
+		// A verse range within one chapter.
+		p.EndChapter, p.EndVerse = p.StartChapter, numbers[2]
+	default:
+		// A chapter range.
+		p.EndChapter = numbers[2]
+	}
+	if p.EndChapter < p.StartChapter || p.EndChapter == p.StartChapter && p.EndVerse != 0 && p.EndVerse < p.StartVerse {
+		return passage{}, fmt.Errorf("reference %q ends before it starts", ref)
+	}
+	if p.StartVerse == 0 && p.EndVerse != 0 {
+		return passage{}, fmt.Errorf("reference %q must give a verse at its start if it gives one at its end", ref)
+	}
+	return p, nil
+}
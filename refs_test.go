@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	books := newBookIndex([]Book{
+		{ID: "spa-RVR1960:Gen", Name: "Génesis"},
+		{ID: "spa-RVR1960:Exod", Name: "Éxodo"},
+		{ID: "spa-RVR1960:John", Name: "Juan"},
+	})
+	tests := []struct {
+		ref  string
+		want passage
+	}{
+		{"spa-RVR1960:Gen.1.1", passage{"spa-RVR1960:Gen", 1, 1, 1, 1}},
+		{"Gen.1", passage{"spa-RVR1960:Gen", 1, 0, 1, 0}},
+		{"Gen.1.1-Gen.1.3", passage{"spa-RVR1960:Gen", 1, 1, 1, 3}},
+		{"Gen.1.1-2.3", passage{"spa-RVR1960:Gen", 1, 1, 2, 3}},
+		{"Gen.1.1-3", passage{"spa-RVR1960:Gen", 1, 1, 1, 3}},
+		{"Gen.1-2", passage{"spa-RVR1960:Gen", 1, 0, 2, 0}},
+		// A single number after the end book is a chapter.
+		{"Gen.1.1-Gen.2", passage{"spa-RVR1960:Gen", 1, 1, 2, 0}},
+		{"Gen.1-spa-RVR1960:Gen.2", passage{"spa-RVR1960:Gen", 1, 0, 2, 0}},
+		{"Juan 3:16", passage{"spa-RVR1960:John", 3, 16, 3, 16}},
+		{"Génesis 1:1-2:3", passage{"spa-RVR1960:Gen", 1, 1, 2, 3}},
+		{"Juan 1-2", passage{"spa-RVR1960:John", 1, 0, 2, 0}},
+	}
+	for _, tt := range tests {
+		got, err := parseReference(tt.ref, books)
+		if err != nil {
+			t.Errorf("parseReference(%q): %v", tt.ref, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseReference(%q) = %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestParseReferenceErrors(t *testing.T) {
+	books := newBookIndex([]Book{
+		{ID: "spa-RVR1960:Gen", Name: "Génesis"},
+		{ID: "spa-RVR1960:Exod", Name: "Éxodo"},
+	})
+	tests := []string{
+		"Gen.50.26-Exod.1.1",
+		"Gen.50-Exod.1",
+		"Gen.1.1-Xyz.1.2",
+		"Gen.2.1-Gen.1",
+		"Gen.1.3-1",
+		"Gen.1-1.3",
+		"Xyz.1.1",
+		"Gen",
+	}
+	for _, ref := range tests {
+		if got, err := parseReference(ref, books); err == nil {
+			t.Errorf("parseReference(%q) = %+v, want an error", ref, got)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+// Regex and wildcard searches scan cleanText in memory with Go's RE2 engine,
+// which runs in time linear in the text, so no pattern can hang the server.
+// Each search is still bounded by SearchConfig.RegexTimeout and a cap on
+// the verses returned, and returns the offsets of every match.
+
+// Pattern syntaxes of the regex search.
+const (
+	SyntaxRegex    = "regex"
+	SyntaxWildcard = "wildcard"
+)
+
+// maxMatchesPerVerse caps the matches reported for a single verse.
+const maxMatchesPerVerse = 50
+
+// regexCheckEvery is how many verses are scanned between checks of the time
+// limit.
+const regexCheckEvery = 256
+
+// corpusVerse is a verse's text as held in memory for regex searches.
+type corpusVerse struct {
+	ID        string `db:"id"`
+	CleanText string `db:"cleanText"`
+}
+
+var (
+	corpusMu     sync.Mutex
+	cachedCorpus []corpusVerse
+)
+
+// loadCorpus returns the text of every verse in canonical order. The text
+// never changes while the server runs, so it is read once, on the first
+// regex search.
+func loadCorpus(ctx context.Context, db *sqlx.DB) ([]corpusVerse, error) {
+	corpusMu.Lock()
+	defer corpusMu.Unlock()
+	if cachedCorpus != nil {
+		return cachedCorpus, nil
+	}
+	verses := []corpusVerse{}
+	if err := dbSelect(ctx, db, "corpus", &verses, `SELECT id, cleanText FROM verses ORDER BY ordinal`); err != nil {
+		return nil, fmt.Errorf("error while getting verses from DB: %v", err)
+	}
+	cachedCorpus = verses
+	return cachedCorpus, nil
+}
+
+// textMatcher finds the matches of a compiled regex or wildcard pattern.
+// Wildcard patterns only match whole words: group 1 holds the match, after
+// the non-word character before it, and a match followed by a letter or
+// digit is dropped.
+type textMatcher struct {
+	re         *regexp.Regexp
+	wholeWords bool
+}
+
+// wildcardPattern turns words with * (any letters) and ? (one letter) into a
+// case-insensitive regex for those words in a row. A lone * is any word.
+func wildcardPattern(pattern string) string {
+	parts := []string{}
+	for _, word := range strings.Fields(pattern) {
+		if word == "*" {
+			parts = append(parts, `[\pL\pN]+`)
+			continue
+		}
+		var b strings.Builder
+		for _, r := range word {
+			switch r {
+			case '*':
+				b.WriteString(`[\pL\pN]*`)
+			case '?':
+				b.WriteString(`[\pL\pN]`)
+			default:
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		parts = append(parts, b.String())
+	}
+	return `(?i)(?:^|[^\pL\pN])(` + strings.Join(parts, `[^\pL\pN]+`) + `)`
+}
+
+// compileTextMatcher compiles pattern in syntax. Patterns that match empty
+// text are rejected, since they would match everywhere.
+func compileTextMatcher(pattern, syntax string) (textMatcher, error) {
+	m := textMatcher{}
+	if syntax == SyntaxWildcard {
+		if strings.TrimSpace(pattern) == "" {
+			return m, fmt.Errorf("the pattern has no words")
+		}
+		pattern = wildcardPattern(pattern)
+		m.wholeWords = true
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return m, err
+	}
+	if re.MatchString("") {
+		return m, fmt.Errorf("the pattern matches empty text")
+	}
+	m.re = re
+	return m, nil
+}
+
+// RegexMatch is a match in a verse's cleanText.
+type RegexMatch struct {
+	Start int    `json:"start" doc:"Posición del primer carácter de la coincidencia en cleanText, contando caracteres Unicode desde 0"`
+	End   int    `json:"end" doc:"Posición siguiente al último carácter de la coincidencia"`
+	Text  string `json:"text" doc:"Texto de la coincidencia"`
+}
+
+// find returns up to limit matches in text, with character offsets.
+func (m textMatcher) find(text string, limit int) []RegexMatch {
+	matches := []RegexMatch{}
+	for _, loc := range m.re.FindAllStringSubmatchIndex(text, limit) {
+		start, end := loc[0], loc[1]
+		if m.wholeWords {
+			start, end = loc[2], loc[3]
+			if next, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && (unicode.IsLetter(next) || unicode.IsDigit(next)) {
+				continue
+			}
+		}
+		runeStart := utf8.RuneCountInString(text[:start])
+		matches = append(matches, RegexMatch{
+			Start: runeStart,
+			End:   runeStart + utf8.RuneCountInString(text[start:end]),
+			Text:  text[start:end],
+		})
+	}
+	return matches
+}
+
+// RegexVerse is a verse with the matches in its cleanText.
+type RegexVerse struct {
+	Verse   Verse        `json:"verse"`
+	Matches []RegexMatch `json:"matches" doc:"Coincidencias en cleanText, en orden, hasta 50 por versículo"`
+}
+
+type RegexSearchResults struct {
+	Pattern   string       `json:"pattern" doc:"Patrón buscado"`
+	Syntax    string       `json:"syntax" doc:"Sintaxis del patrón"`
+	Verses    []RegexVerse `json:"verses" doc:"Versículos con alguna coincidencia, en orden canónico"`
+	Truncated bool         `json:"truncated" doc:"true si hay más versículos que limit"`
+	TimedOut  bool         `json:"timedOut" doc:"true si la búsqueda superó el tiempo máximo y verses tiene solo los versículos encontrados hasta entonces"`
+}
+
+type RegexSearchRequest struct {
+	VerseFieldsRequest
+	Pattern string `query:"q" required:"true" maxLength:"200" doc:"Patrón a buscar en cleanText. Con syntax=regex, una expresión regular RE2 (https://github.com/google/re2/wiki/Syntax) que distingue mayúsculas salvo con (?i), ej: (?i)\\bamor\\w*; con syntax=wildcard, palabras donde * es cualquier número de letras y ? una sola, sin distinguir mayúsculas, ej: am* o * de Dios"`
+	Syntax  string `query:"syntax" enum:"regex,wildcard" default:"regex" doc:"Sintaxis del patrón"`
+	Limit   int    `query:"limit" minimum:"1" maximum:"1000" default:"100" doc:"Máximo de versículos a devolver"`
+}
+
+func (i *RegexSearchRequest) Resolve(ctx huma.Context) []error {
+	if _, err := compileTextMatcher(i.Pattern, i.Syntax); err != nil {
+		return []error{&huma.ErrorDetail{
+			Location: "query.q",
+			Message:  err.Error(),
+			Value:    i.Pattern,
+		}}
+	}
+	return nil
+}
+
+// regexSearch returns up to limit verses whose cleanText matches m, in
+// canonical order, scanning for at most timeout.
+func regexSearch(ctx context.Context, db *sqlx.DB, m textMatcher, limit int, timeout time.Duration, fields verseSelection) (RegexSearchResults, error) {
+	results := RegexSearchResults{Verses: []RegexVerse{}}
+	corpus, err := loadCorpus(ctx, db)
+	if err != nil {
+		return results, err
+	}
+	deadline := time.Now().Add(timeout)
+	ids := []string{}
+	for i, v := range corpus {
+		if i%regexCheckEvery == 0 && (time.Now().After(deadline) || ctx.Err() != nil) {
+			results.TimedOut = true
+			break
+		}
+		matches := m.find(v.CleanText, maxMatchesPerVerse)
+		if len(matches) == 0 {
+			continue
+		}
+		if len(results.Verses) == limit {
+			results.Truncated = true
+			break
+		}
+		results.Verses = append(results.Verses, RegexVerse{Verse: Verse{ID: v.ID}, Matches: matches})
+		ids = append(ids, v.ID)
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	verses := []Verse{}
+	if err := selectIn(ctx, db, "regex_verses", &verses, `SELECT `+fields.columns()+` FROM verses WHERE id IN (?)`, ids); err != nil {
+		return results, err
+	}
+	fields.apply(verses)
+	byId := map[string]Verse{}
+	for _, v := range verses {
+		byId[v.ID] = v
+	}
+	for i := range results.Verses {
+		results.Verses[i].Verse = byId[results.Verses[i].Verse.ID]
+	}
+	return results, nil
+}
+
+func registerRegexRoutes(api huma.API, db *sqlx.DB, cfg SearchConfig) {
+	huma.Register(api, huma.Operation{
+		OperationID: "search-verses-regex",
+		Method:      http.MethodGet,
+		Path:        "/api/verses/search/regex",
+		Summary:     "Buscar versículos con una expresión regular o comodines",
+		Description: fmt.Sprintf("Devuelve, en orden canónico, los versículos cuyo cleanText coincide con el patrón, con la posición de cada coincidencia. La búsqueda se detiene después de %s y devuelve los versículos encontrados hasta entonces con timedOut=true.", cfg.RegexTimeout),
+		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeSearch),
+	}, func(ctx context.Context, input *RegexSearchRequest) (*SingleResponse[RegexSearchResults], error) {
+		m, err := compileTextMatcher(input.Pattern, input.Syntax)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		results, err := regexSearch(ctx, db, m, input.Limit, cfg.RegexTimeout, input.selection())
+		if err != nil {
+			return nil, fmt.Errorf("error while getting verses from DB: %v", err)
+		}
+		results.Pattern = input.Pattern
+		results.Syntax = input.Syntax
+		searchResults.WithLabelValues(input.Syntax).Observe(float64(len(results.Verses)))
+		return &SingleResponse[RegexSearchResults]{
+			Body: results,
+		}, nil
+	})
+}
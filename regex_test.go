@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWildcardPattern(t *testing.T) {
+	tests := []struct {
+		pattern, want string
+	}{
+		{"amor", `(?i)(?:^|[^\pL\pN])(amor)`},
+		{"am*", `(?i)(?:^|[^\pL\pN])(am[\pL\pN]*)`},
+		{"am?r", `(?i)(?:^|[^\pL\pN])(am[\pL\pN]r)`},
+		{"* de Dios", `(?i)(?:^|[^\pL\pN])([\pL\pN]+[^\pL\pN]+de[^\pL\pN]+Dios)`},
+		// Other regex syntax is quoted.
+		{"a.b", `(?i)(?:^|[^\pL\pN])(a\.b)`},
+	}
+	for _, tt := range tests {
+		if got := wildcardPattern(tt.pattern); got != tt.want {
+			t.Errorf("wildcardPattern(%q) = %s, want %s", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestTextMatcherFind(t *testing.T) {
+	tests := []struct {
+		name, pattern, syntax, text string
+		limit                       int
+		want                        []RegexMatch
+	}{
+		{
+			name:    "wildcard prefix",
+			pattern: "am*", syntax: SyntaxWildcard,
+			text:  "Amados, amémonos unos a otros",
+			limit: 10,
+			want:  []RegexMatch{{0, 6, "Amados"}, {8, 16, "amémonos"}},
+		},
+		{
+			name:    "wildcard matches whole words only",
+			pattern: "am?", syntax: SyntaxWildcard,
+			text:  "amor ama am",
+			limit: 10,
+			want:  []RegexMatch{{5, 8, "ama"}},
+		},
+		{
+			name:    "wildcard word inside another is not matched",
+			pattern: "dios", syntax: SyntaxWildcard,
+			text:  "semidioses y Dios",
+			limit: 10,
+			want:  []RegexMatch{{13, 17, "Dios"}},
+		},
+		{
+			name:    "wildcard phrase after accents",
+			pattern: "* de Dios", syntax: SyntaxWildcard,
+			text:  "Y el Espíritu de Dios se movía",
+			limit: 10,
+			want:  []RegexMatch{{5, 21, "Espíritu de Dios"}},
+		},
+		{
+			name:    "wildcard ignores the case of accented letters",
+			pattern: "JERUSALÉN", syntax: SyntaxWildcard,
+			text:  "vino Nabucodonosor rey de Babilonia a Jerusalén, y la sitió.",
+			limit: 10,
+			want:  []RegexMatch{{38, 47, "Jerusalén"}},
+		},
+		{
+			name:    "regex offsets count characters, not bytes",
+			pattern: "Dios", syntax: SyntaxRegex,
+			text:  "Y acabó Dios en el día séptimo",
+			limit: 10,
+			want:  []RegexMatch{{8, 12, "Dios"}},
+		},
+		{
+			name:    "regex \\w does not match accented letters",
+			pattern: `s\w*`, syntax: SyntaxRegex,
+			text:  "día séptimo",
+			limit: 10,
+			want:  []RegexMatch{{4, 5, "s"}},
+		},
+		{
+			name:    "regex limit",
+			pattern: "(?i)la", syntax: SyntaxRegex,
+			text:  "La luz, la tierra y la faz",
+			limit: 2,
+			want:  []RegexMatch{{0, 2, "La"}, {8, 10, "la"}},
+		},
+		{
+			name:    "no match",
+			pattern: "amor", syntax: SyntaxRegex,
+			text:  "En el principio",
+			limit: 10,
+			want:  []RegexMatch{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := compileTextMatcher(tt.pattern, tt.syntax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.find(tt.text, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("find(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileTextMatcherErrors(t *testing.T) {
+	tests := []struct {
+		pattern, syntax string
+	}{
+		{"  ", SyntaxWildcard},
+		{"(", SyntaxRegex},
+		{"a*", SyntaxRegex},
+	}
+	for _, tt := range tests {
+		if _, err := compileTextMatcher(tt.pattern, tt.syntax); err == nil {
+			t.Errorf("compileTextMatcher(%q, %s) compiled, want an error", tt.pattern, tt.syntax)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// sideServer is a server, like gRPC, that runs alongside the HTTP API on its
+// own address and starts and stops with it.
+type sideServer struct {
+	name string
+	addr string
+	// serve serves on the listener until shutdown is called.
+	serve func(net.Listener) error
+	// shutdown stops accepting connections and waits for in-flight
+	// requests until ctx is done.
+	shutdown func(ctx context.Context) error
+}
+
+// runServer serves handler on cfg.Addr, and every side server on its own
+// address, until the process receives SIGINT or SIGTERM, then stops
+// accepting connections and waits up to cfg.Timeouts.Shutdown for in-flight
+// requests to finish. It returns an error if an address cannot be listened
+// on or the connections could not be drained in time.
+func runServer(cfg Config, handler http.Handler, sides ...sideServer) error {
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.Timeouts.Read,
+		ReadHeaderTimeout: cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+	}
+	servers := append([]sideServer{{
+		name:     "http",
+		addr:     cfg.Addr,
+		serve:    server.Serve,
+		shutdown: server.Shutdown,
+	}}, sides...)
+
+	// Listen before serving so a port conflict is reported straight away
+	// instead of after the signal handler is installed.
+	listeners := []net.Listener{}
+	for _, s := range servers {
+		listener, err := net.Listen("tcp", s.addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("error while listening on %s: %v", s.addr, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, len(servers))
+	for i, s := range servers {
+		go func() {
+			if err := s.serve(listeners[i]); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("error while serving %s on %s: %v", s.name, s.addr, err)
+			}
+		}()
+		slog.Info("starting server", "server", s.name, "addr", listeners[i].Addr().String())
+	}
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down, draining connections", "timeout", cfg.Timeouts.Shutdown)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancel()
+	var wg sync.WaitGroup
+	shutdownErrs := make([]error, len(servers))
+	for i, s := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.shutdown(shutdownCtx); err != nil {
+				shutdownErrs[i] = fmt.Errorf("error while draining %s connections: %v", s.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if shutdownErr := errors.Join(shutdownErrs...); shutdownErr != nil {
+		server.Close()
+		return errors.Join(err, shutdownErr)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("server stopped")
+	return nil
+}
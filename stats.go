@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/kljensen/snowball/spanish"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Corpus statistics are computed from verses.cleanText the first time they
+// are asked for and kept in memory, since the text never changes while the
+// server runs. Rankings that depend on the request, such as the most
+// frequent words, are cached in an LRU cache. Words are split and
+// normalized like the concordance's.
+
+// VerseWordCount is the number of words of a verse.
+type VerseWordCount struct {
+	ID        string `json:"id" doc:"Id del versículo"`
+	Reference string `json:"reference" doc:"Referencia del versículo, ej: Juan 3:16"`
+	Verse     int    `json:"verse" doc:"Número de versículo"`
+	Words     int    `json:"words" doc:"Número de palabras"`
+}
+
+// ChapterWordCount is the number of verses and words of a chapter.
+type ChapterWordCount struct {
+	ID      string `json:"id" doc:"Id del capítulo"`
+	Chapter int    `json:"chapter" doc:"Número de capítulo"`
+	Verses  int    `json:"verses" doc:"Número de versículos"`
+	Words   int    `json:"words" doc:"Número de palabras"`
+}
+
+// BookWordCount is the size of a book.
+type BookWordCount struct {
+	ID         string `json:"id" doc:"Id del libro"`
+	Name       string `json:"name" doc:"Nombre del libro"`
+	Testament  string `json:"testament" doc:"OT o NT"`
+	Chapters   int    `json:"chapters" doc:"Número de capítulos"`
+	Verses     int    `json:"verses" doc:"Número de versículos"`
+	Words      int    `json:"words" doc:"Número de palabras"`
+	Vocabulary int    `json:"vocabulary" doc:"Número de palabras distintas"`
+}
+
+type CorpusStats struct {
+	Books      int             `json:"books" doc:"Número de libros"`
+	Chapters   int             `json:"chapters" doc:"Número de capítulos"`
+	Verses     int             `json:"verses" doc:"Número de versículos"`
+	Words      int             `json:"words" doc:"Número de palabras"`
+	Vocabulary int             `json:"vocabulary" doc:"Número de palabras distintas"`
+	PerBook    []BookWordCount `json:"perBook" doc:"Tamaño de cada libro, en orden canónico"`
+}
+
+type BookStats struct {
+	BookWordCount
+	PerChapter []ChapterWordCount `json:"perChapter" doc:"Tamaño de cada capítulo, en orden"`
+}
+
+type ChapterStats struct {
+	ChapterWordCount
+	PerVerse []VerseWordCount `json:"perVerse" doc:"Número de palabras de cada versículo, en orden"`
+}
+
+// WordFrequency is how often a word occurs.
+type WordFrequency struct {
+	Word   string `json:"word" doc:"La palabra normalizada"`
+	Count  int    `json:"count" doc:"Número de apariciones"`
+	Verses int    `json:"verses" doc:"Número de versículos donde aparece"`
+}
+
+// BookFrequency is how often a word occurs in a book.
+type BookFrequency struct {
+	BookID string `json:"bookId" doc:"Id del libro"`
+	Name   string `json:"name" doc:"Nombre del libro"`
+	Count  int    `json:"count" doc:"Número de apariciones en el libro"`
+	Verses int    `json:"verses" doc:"Número de versículos del libro donde aparece"`
+	// PerTenThousand makes books of different lengths comparable.
+	PerTenThousand float64 `json:"perTenThousand" doc:"Apariciones por cada 10.000 palabras del libro, para comparar libros de distinto tamaño"`
+}
+
+type WordDistribution struct {
+	Word    string          `json:"word" doc:"La palabra normalizada"`
+	Count   int             `json:"count" doc:"Número total de apariciones"`
+	Verses  int             `json:"verses" doc:"Número de versículos donde aparece"`
+	PerBook []BookFrequency `json:"perBook" doc:"Apariciones en cada libro, en orden canónico, incluidos los libros donde no aparece"`
+}
+
+// TextLength is the length of a verse or chapter.
+type TextLength struct {
+	ID        string `json:"id" doc:"Id del versículo o capítulo"`
+	Reference string `json:"reference" doc:"Referencia, ej: Juan 3:16 o Juan 3"`
+	Words     int    `json:"words" doc:"Número de palabras"`
+}
+
+// wordCounts holds, for each word, its number of occurrences and of verses
+// containing it.
+type wordCounts map[string]*WordFrequency
+
+func (c wordCounts) addVerse(words []string) {
+	seen := map[string]bool{}
+	for _, w := range words {
+		f, ok := c[w]
+		if !ok {
+			f = &WordFrequency{Word: w}
+			c[w] = f
+		}
+		f.Count++
+		if !seen[w] {
+			seen[w] = true
+			f.Verses++
+		}
+	}
+}
+
+type bookCorpus struct {
+	BookWordCount
+	chapters []ChapterStats
+	words    wordCounts
+}
+
+// corpusStats is every count the statistics are derived from.
+type corpusStats struct {
+	summary CorpusStats
+	books   []*bookCorpus
+	byId    map[string]*bookCorpus
+	words   wordCounts
+	// stopwords holds the normalized words written somewhere as a
+	// stopword. The Snowball list has accented forms, like "más" or "él",
+	// so words are checked before their accents are removed.
+	stopwords map[string]bool
+}
+
+var (
+	statsMu     sync.Mutex
+	cachedStats *corpusStats
+)
+
+// loadCorpusStats returns the statistics of the text, computing them the
+// first time.
+func loadCorpusStats(ctx context.Context, db *sqlx.DB) (*corpusStats, error) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if cachedStats != nil {
+		return cachedStats, nil
+	}
+	books, err := listBooks(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting books from DB: %v", err)
+	}
+	stats := &corpusStats{byId: map[string]*bookCorpus{}, words: wordCounts{}, stopwords: map[string]bool{}}
+	for _, b := range books {
+		book := &bookCorpus{BookWordCount: BookWordCount{ID: b.ID, Name: b.Name, Testament: b.Testament}, words: wordCounts{}}
+		stats.books = append(stats.books, book)
+		stats.byId[b.ID] = book
+	}
+
+	err = dbEach(ctx, db, "stats_verses", func(v struct {
+		Verse
+		BookID string `db:"bookId"`
+	}) error {
+		book, ok := stats.byId[v.BookID]
+		if !ok {
+			return nil
+		}
+		words := words(strings.ToLower(norm.NFC.String(v.CleanText)))
+		for i, written := range words {
+			words[i] = searchAnalyzer.fold(written)
+			if spanish.IsStopWord(written) {
+				stats.stopwords[words[i]] = true
+			}
+		}
+		if len(book.chapters) == 0 || book.chapters[len(book.chapters)-1].ID != v.ChapterId {
+			book.chapters = append(book.chapters, ChapterStats{ChapterWordCount: ChapterWordCount{ID: v.ChapterId, Chapter: v.ChapterNumber}})
+		}
+		chapter := &book.chapters[len(book.chapters)-1]
+		chapter.PerVerse = append(chapter.PerVerse, VerseWordCount{ID: v.ID, Reference: v.Reference, Verse: v.VerseNumber, Words: len(words)})
+		chapter.Verses++
+		chapter.Words += len(words)
+		book.Verses++
+		book.Words += len(words)
+		book.words.addVerse(words)
+		stats.words.addVerse(words)
+		return nil
+	}, `SELECT id, chapterId, reference, chapterNumber, verseNumber, cleanText, bookId FROM verses ORDER BY ordinal`)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting verses from DB: %v", err)
+	}
+
+	summary := CorpusStats{Books: len(stats.books), Vocabulary: len(stats.words), PerBook: []BookWordCount{}}
+	for _, book := range stats.books {
+		book.Chapters = len(book.chapters)
+		book.Vocabulary = len(book.words)
+		summary.Chapters += book.Chapters
+		summary.Verses += book.Verses
+		summary.Words += book.Words
+		summary.PerBook = append(summary.PerBook, book.BookWordCount)
+	}
+	stats.summary = summary
+	cachedStats = stats
+	return cachedStats, nil
+}
+
+// topWords returns the limit most frequent words of counts, most frequent
+// first, without the words in stopwords.
+func topWords(counts wordCounts, limit int, stopwords map[string]bool) []WordFrequency {
+	result := []WordFrequency{}
+	for word, f := range counts {
+		if !stopwords[word] {
+			result = append(result, *f)
+		}
+	}
+	slices.SortFunc(result, func(a, b WordFrequency) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Word, b.Word)
+	})
+	return result[:min(limit, len(result))]
+}
+
+// distribution returns how often word occurs in each book.
+func (s *corpusStats) distribution(word string) WordDistribution {
+	result := WordDistribution{Word: word, PerBook: []BookFrequency{}}
+	for _, book := range s.books {
+		frequency := BookFrequency{BookID: book.ID, Name: book.Name}
+		if f, ok := book.words[word]; ok {
+			frequency.Count, frequency.Verses = f.Count, f.Verses
+			result.Count += f.Count
+			result.Verses += f.Verses
+		}
+		if book.Words > 0 {
+			frequency.PerTenThousand = float64(frequency.Count) * 10000 / float64(book.Words)
+		}
+		result.PerBook = append(result.PerBook, frequency)
+	}
+	return result
+}
+
+// lengths returns the limit longest, or shortest, verses or chapters of the
+// books, ties in canonical order.
+func (s *corpusStats) lengths(books []*bookCorpus, unit string, shortest bool, limit int) []TextLength {
+	result := []TextLength{}
+	for _, book := range books {
+		for _, chapter := range book.chapters {
+			if unit == "chapter" {
+				result = append(result, TextLength{ID: chapter.ID, Reference: fmt.Sprintf("%s %d", book.Name, chapter.Chapter), Words: chapter.Words})
+				continue
+			}
+			for _, v := range chapter.PerVerse {
+				result = append(result, TextLength{ID: v.ID, Reference: v.Reference, Words: v.Words})
+			}
+		}
+	}
+	slices.SortStableFunc(result, func(a, b TextLength) int {
+		if shortest {
+			return a.Words - b.Words
+		}
+		return b.Words - a.Words
+	})
+	return result[:min(limit, len(result))]
+}
+
+type StatsBookRequest struct {
+	BookRequest
+}
+
+type StatsChapterRequest struct {
+	BookRequest
+	ChapterNumber uint `path:"chapterNumber" required:"true" doc:"Número del capítulo"`
+}
+
+type TopWordsRequest struct {
+	Book      string `query:"book" doc:"Id del libro del que contar las palabras, ej: spa-RVR1960:John; toda la Biblia si se omite"`
+	Limit     int    `query:"limit" minimum:"1" maximum:"1000" default:"50" doc:"Número de palabras a devolver"`
+	Stopwords bool   `query:"stopwords" doc:"true para incluir palabras vacías como de, la o que, que se omiten por defecto"`
+}
+
+type WordDistributionRequest struct {
+	Word string `path:"word" maxLength:"50" doc:"Palabra; no distingue mayúsculas ni acentos"`
+}
+
+type LengthsRequest struct {
+	Unit  string `query:"unit" enum:"verse,chapter" default:"verse" doc:"Medir versículos o capítulos"`
+	Order string `query:"order" enum:"longest,shortest" default:"longest" doc:"Devolver los más largos o los más cortos"`
+	Book  string `query:"book" doc:"Id del libro al que limitar la búsqueda, ej: spa-RVR1960:Ps; toda la Biblia si se omite"`
+	Limit int    `query:"limit" minimum:"1" maximum:"100" default:"10" doc:"Número de versículos o capítulos a devolver"`
+}
+
+func registerStatsRoutes(api huma.API, db *sqlx.DB, cacheEntries int) {
+	rankings := newLRUCache[string, any]("stats", cacheEntries)
+
+	// booksFor returns the book with id bookId, or every book if it is
+	// empty.
+	booksFor := func(stats *corpusStats, bookId string) ([]*bookCorpus, error) {
+		if bookId == "" {
+			return stats.books, nil
+		}
+		book, ok := stats.byId[bookId]
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("book not found: %s", bookId))
+		}
+		return []*bookCorpus{book}, nil
+	}
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-stats",
+		Method:      http.MethodGet,
+		Path:        "/api/stats",
+		Summary:     "Obtener estadísticas del texto",
+		Description: "Devuelve el número de libros, capítulos, versículos, palabras y palabras distintas de la Biblia y de cada libro.",
+		Tags:        []string{"Stats"},
+		Security:    requireScope(ScopeRead),
+	}, func(ctx context.Context, input *struct{}) (*SingleResponse[CorpusStats], error) {
+		stats, err := loadCorpusStats(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		return &SingleResponse[CorpusStats]{Body: stats.summary}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-book-stats",
+		Method:      http.MethodGet,
+		Path:        "/api/stats/books/{bookId}",
+		Summary:     "Obtener estadísticas de un libro",
+		Description: "Devuelve el tamaño de un libro y el número de versículos y palabras de cada capítulo.",
+		Tags:        []string{"Stats"},
+		Security:    requireScope(ScopeRead),
+	}, func(ctx context.Context, input *StatsBookRequest) (*SingleResponse[BookStats], error) {
+		stats, err := loadCorpusStats(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		books, err := booksFor(stats, input.BookId)
+		if err != nil {
+			return nil, err
+		}
+		result := BookStats{BookWordCount: books[0].BookWordCount, PerChapter: []ChapterWordCount{}}
+		for _, chapter := range books[0].chapters {
+			result.PerChapter = append(result.PerChapter, chapter.ChapterWordCount)
+		}
+		return &SingleResponse[BookStats]{Body: result}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-chapter-stats",
+		Method:      http.MethodGet,
+		Path:        "/api/stats/books/{bookId}/chapters/{chapterNumber}",
+		Summary:     "Obtener estadísticas de un capítulo",
+		Description: "Devuelve el número de versículos y palabras de un capítulo y el número de palabras de cada versículo.",
+		Tags:        []string{"Stats"},
+		Security:    requireScope(ScopeRead),
+	}, func(ctx context.Context, input *StatsChapterRequest) (*SingleResponse[ChapterStats], error) {
+		stats, err := loadCorpusStats(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		books, err := booksFor(stats, input.BookId)
+		if err != nil {
+			return nil, err
+		}
+		for _, chapter := range books[0].chapters {
+			if chapter.Chapter == int(input.ChapterNumber) {
+				return &SingleResponse[ChapterStats]{Body: chapter}, nil
+			}
+		}
+		return nil, huma.Error404NotFound(fmt.Sprintf("chapter not found: %s.%d", input.BookId, input.ChapterNumber))
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-top-words",
+		Method:      http.MethodGet,
+		Path:        "/api/stats/words",
+		Summary:     "Obtener las palabras más frecuentes",
+		Description: "Devuelve las palabras más frecuentes de la Biblia o de un libro, normalizadas sin mayúsculas ni acentos, con su número de apariciones y de versículos. Las palabras vacías (de, la, que, y...) se omiten salvo con stopwords=true.",
+		Tags:        []string{"Stats"},
+		Security:    requireScope(ScopeRead),
+	}, func(ctx context.Context, input *TopWordsRequest) (*ListResponse[WordFrequency], error) {
+		key := fmt.Sprintf("words|%s|%d|%t", input.Book, input.Limit, input.Stopwords)
+		if cached, ok := rankings.Get(key); ok {
+			return &ListResponse[WordFrequency]{Body: cached.([]WordFrequency)}, nil
+		}
+		stats, err := loadCorpusStats(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		counts := stats.words
+		if input.Book != "" {
+			books, err := booksFor(stats, input.Book)
+			if err != nil {
+				return nil, err
+			}
+			counts = books[0].words
+		}
+		stopwords := stats.stopwords
+		if input.Stopwords {
+			stopwords = nil
+		}
+		result := topWords(counts, input.Limit, stopwords)
+		rankings.Add(key, result)
+		return &ListResponse[WordFrequency]{Body: result}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-word-distribution",
+		Method:      http.MethodGet,
+		Path:        "/api/stats/words/{word}",
+		Summary:     "Obtener la distribución de una palabra por libros",
+		Description: "Devuelve cuántas veces aparece una palabra en cada libro, en orden canónico, también en proporción al tamaño del libro, para graficar su distribución.",
+		Tags:        []string{"Stats"},
+		Security:    requireScope(ScopeRead),
+	}, func(ctx context.Context, input *WordDistributionRequest) (*SingleResponse[WordDistribution], error) {
+		normalized := words(searchAnalyzer.fold(input.Word))
+		if len(normalized) != 1 {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("expected a single word, got %q", input.Word))
+		}
+		stats, err := loadCorpusStats(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := stats.words[normalized[0]]; !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("word not found in the text: %s", normalized[0]))
+		}
+		return &SingleResponse[WordDistribution]{Body: stats.distribution(normalized[0])}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-text-lengths",
+		Method:      http.MethodGet,
+		Path:        "/api/stats/lengths",
+		Summary:     "Obtener los versículos o capítulos más largos o más cortos",
+		Description: "Devuelve los versículos o capítulos con más o con menos palabras de la Biblia o de un libro. Los empates se devuelven en orden canónico.",
+		Tags:        []string{"Stats"},
+		Security:    requireScope(ScopeRead),
+	}, func(ctx context.Context, input *LengthsRequest) (*ListResponse[TextLength], error) {
+		key := fmt.Sprintf("lengths|%s|%s|%s|%d", input.Unit, input.Order, input.Book, input.Limit)
+		if cached, ok := rankings.Get(key); ok {
+			return &ListResponse[TextLength]{Body: cached.([]TextLength)}, nil
+		}
+		stats, err := loadCorpusStats(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		books, err := booksFor(stats, input.Book)
+		if err != nil {
+			return nil, err
+		}
+		result := stats.lengths(books, input.Unit, input.Order == "shortest", input.Limit)
+		rankings.Add(key, result)
+		return &ListResponse[TextLength]{Body: result}, nil
+	})
+}
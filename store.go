@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// The functions in this file read books, chapters and verses for every way
+// the API is served, so REST, GraphQL and the other front ends return the
+// same data from the same queries. Single lookups return sql.ErrNoRows when
+// nothing matches; the *ByIds functions, used for batching, return whatever
+// rows exist in no particular order.
+
+const bookColumns = `id, name, "order", testament`
+
+const chapterColumns = `chapter, id, osis_end`
+
+// listBooks returns every book in canonical order with its chapters.
+func listBooks(ctx context.Context, db *sqlx.DB) ([]Book, error) {
+	books := []Book{}
+	chapters := []Chapter{}
+	if err := dbSelect(ctx, db, "books", &books, `SELECT `+bookColumns+` FROM books ORDER BY "order"`); err != nil {
+		return nil, err
+	}
+	if err := dbSelect(ctx, db, "chapters", &chapters, `SELECT `+chapterColumns+` FROM chapters`); err != nil {
+		return nil, err
+	}
+	for i := range books {
+		bookChapters := Filter(chapters, func(c Chapter) bool {
+			return strings.Contains(c.ID, books[i].ID)
+		})
+		slices.SortFunc(bookChapters, func(c1 Chapter, c2 Chapter) int {
+			return c1.Chapter - c2.Chapter
+		})
+		books[i].Chapters = append(books[i].Chapters, bookChapters...)
+	}
+	return books, nil
+}
+
+// getBook returns a book with its chapters.
+func getBook(ctx context.Context, db *sqlx.DB, bookId string) (Book, error) {
+	book := Book{}
+	if err := dbGet(ctx, db, "book", &book, `SELECT `+bookColumns+` FROM books WHERE id = ?`, bookId); err != nil {
+		return book, err
+	}
+	err := dbSelect(ctx, db, "book_chapters", &book.Chapters, `SELECT `+chapterColumns+` FROM chapters WHERE id like ? ORDER BY chapter`, "%"+book.ID+"%")
+	return book, err
+}
+
+// chapterVerses returns the verses of a chapter in order.
+func chapterVerses(ctx context.Context, db *sqlx.DB, chapterId string, fields verseSelection) ([]Verse, error) {
+	verses := []Verse{}
+	err := dbSelect(ctx, db, "verses_by_chapter", &verses, `SELECT `+fields.columns()+` FROM verses WHERE chapterId = ? ORDER BY verseNumber`, chapterId)
+	fields.apply(verses)
+	return verses, err
+}
+
+// getVerse returns a single verse.
+func getVerse(ctx context.Context, db *sqlx.DB, verseId string, fields verseSelection) (Verse, error) {
+	verse := Verse{}
+	err := dbGet(ctx, db, "verse", &verse, `SELECT `+fields.columns()+` FROM verses WHERE id = ?`, verseId)
+	fields.applyOne(&verse)
+	return verse, err
+}
+
+// searchVerses returns the verses matching query in the search mode, in
+// canonical order.
+func searchVerses(ctx context.Context, db *sqlx.DB, query, mode string, fields verseSelection) ([]Verse, error) {
+	verses := []Verse{}
+	condition, args := searchCondition(query, mode)
+	err := dbSelect(ctx, db, "search", &verses, `SELECT `+fields.columns()+` FROM verses`+condition+` ORDER BY ordinal`, args...)
+	fields.apply(verses)
+	return verses, err
+}
+
+// countSearch returns the number of verses matching query in the search
+// mode.
+func countSearch(ctx context.Context, db *sqlx.DB, query, mode string) (int, error) {
+	count := 0
+	condition, args := searchCondition(query, mode)
+	err := dbGet(ctx, db, "search_count", &count, `SELECT count(*) FROM verses`+condition, args...)
+	return count, err
+}
+
+// streamSearch calls fn with each verse matching query in the search mode,
+// in canonical order, as it is read. It stops at the first error fn returns.
+func streamSearch(ctx context.Context, db *sqlx.DB, query, mode string, fields verseSelection, fn func(Verse) error) error {
+	condition, args := searchCondition(query, mode)
+	return dbEach(ctx, db, "search", func(v Verse) error {
+		fields.applyOne(&v)
+		return fn(v)
+	}, `SELECT `+fields.columns()+` FROM verses`+condition+` ORDER BY ordinal`, args...)
+}
+
+// streamPassage calls fn with each verse of the passage, in order, as it is
+// read. It stops at the first error fn returns.
+func streamPassage(ctx context.Context, db *sqlx.DB, p passage, fields verseSelection, fn func(Verse) error) error {
+	first, last := p.bounds()
+	return dbEach(ctx, db, "verses_passage", func(v Verse) error {
+		fields.applyOne(&v)
+		return fn(v)
+	}, `SELECT `+fields.columns()+` FROM verses
+		WHERE bookId = ? AND chapterNumber BETWEEN ? AND ? AND chapterNumber * 1000 + verseNumber BETWEEN ? AND ?
+		ORDER BY chapterNumber, verseNumber`, p.BookID, p.StartChapter, p.EndChapter, first, last)
+}
+
+// searchVersesInBooks returns, in canonical order, up to limit verses of the
+// books matching query in the search mode. No bookIds searches every book.
+func searchVersesInBooks(ctx context.Context, db *sqlx.DB, query, mode string, bookIds []string, limit int, fields verseSelection) ([]Verse, error) {
+	verses := []Verse{}
+	condition, args := searchCondition(query, mode)
+	sql := `SELECT ` + fields.columns() + ` FROM verses` + condition
+	if len(bookIds) > 0 {
+		sql += ` AND bookId IN (?)`
+		args = append(args, bookIds)
+	}
+	sql, args, err := sqlx.In(sql+` ORDER BY ordinal LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	err = dbSelect(ctx, db, "search_in_books", &verses, sql, args...)
+	fields.apply(verses)
+	return verses, err
+}
+
+// selectIn runs a query with a single IN (?) placeholder expanded to ids.
+func selectIn(ctx context.Context, db *sqlx.DB, name string, dest any, query string, ids []string) error {
+	query, args, err := sqlx.In(query, ids)
+	if err != nil {
+		return err
+	}
+	return dbSelect(ctx, db, name, dest, query, args...)
+}
+
+func booksByIds(ctx context.Context, db *sqlx.DB, ids []string) ([]Book, error) {
+	books := []Book{}
+	err := selectIn(ctx, db, "books_by_ids", &books, `SELECT `+bookColumns+` FROM books WHERE id IN (?)`, ids)
+	return books, err
+}
+
+func chaptersByIds(ctx context.Context, db *sqlx.DB, ids []string) ([]Chapter, error) {
+	chapters := []Chapter{}
+	err := selectIn(ctx, db, "chapters_by_ids", &chapters, `SELECT `+chapterColumns+` FROM chapters WHERE id IN (?)`, ids)
+	return chapters, err
+}
+
+// chaptersByBookIds returns the chapters of the books, ordered by chapter.
+func chaptersByBookIds(ctx context.Context, db *sqlx.DB, bookIds []string) ([]Chapter, error) {
+	chapters := []Chapter{}
+	err := selectIn(ctx, db, "chapters_by_book_ids", &chapters, `SELECT `+chapterColumns+` FROM chapters WHERE bookId IN (?) ORDER BY chapter`, bookIds)
+	return chapters, err
+}
+
+func versesByIds(ctx context.Context, db *sqlx.DB, ids []string) ([]Verse, error) {
+	verses := []Verse{}
+	err := selectIn(ctx, db, "verses_by_ids", &verses, `SELECT `+allVerseFields.columns()+` FROM verses WHERE id IN (?)`, ids)
+	return verses, err
+}
+
+// versesByChapterIds returns the verses of the chapters, ordered by verse.
+func versesByChapterIds(ctx context.Context, db *sqlx.DB, chapterIds []string) ([]Verse, error) {
+	verses := []Verse{}
+	err := selectIn(ctx, db, "verses_by_chapter_ids", &verses, `SELECT `+allVerseFields.columns()+` FROM verses WHERE chapterId IN (?) ORDER BY verseNumber`, chapterIds)
+	return verses, err
+}
+
+// AdjacentVerse is a verse found next to, or before, the verse FromID.
+type AdjacentVerse struct {
+	FromID string `db:"fromId"`
+	Verse
+}
+
+// adjacentVerses returns the verses offset positions away from each of ids
+// in canonical order, crossing chapter and book boundaries.
+func adjacentVerses(ctx context.Context, db *sqlx.DB, ids []string, offset int) ([]AdjacentVerse, error) {
+	verses := []AdjacentVerse{}
+	columns := []string{}
+	for _, c := range verseColumns {
+		columns = append(columns, "a."+c.column)
+	}
+	query, args, err := sqlx.In(`SELECT v.id AS fromId, `+strings.Join(columns, ",")+`
+		FROM verses v JOIN verses a ON a.ordinal = v.ordinal + ?
+		WHERE v.id IN (?)`, offset, ids)
+	if err != nil {
+		return nil, err
+	}
+	err = dbSelect(ctx, db, "adjacent_verses", &verses, query, args...)
+	return verses, err
+}
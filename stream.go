@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/sse"
+	"github.com/jmoiron/sqlx"
+)
+
+// The streaming variants of the range and search operations send each verse
+// as soon as its row is read, instead of building the whole array in memory
+// first, and end with a summary. They are served as Server-Sent Events on
+// .../stream and as newline delimited JSON on .../ndjson.
+
+// streamingOperation is the Operation.Metadata key set on streaming
+// operations, whose responses are never buffered or cached.
+const streamingOperation = "streaming"
+
+// VerseStreamSummary is the last event of every verse stream.
+type VerseStreamSummary struct {
+	Count   int    `json:"count" doc:"Número de versículos enviados"`
+	Passage string `json:"passage,omitempty" doc:"Referencia OSIS del rango pedido, ej: spa-RVR1960:Gen.1-Gen.3"`
+	Query   string `json:"query,omitempty" doc:"Texto buscado"`
+	// Suggestions are set like the Search-Suggestions header of the
+	// search operation, without the encoding.
+	Suggestions []string `json:"suggestions,omitempty" doc:"Si la búsqueda encontró pocos versículos, búsquedas corregidas que encuentran más"`
+	Error       string   `json:"error,omitempty" doc:"Si está presente, el stream terminó antes de tiempo por este error y los versículos enviados están incompletos"`
+}
+
+// VerseStreamLine is one line of an NDJSON verse stream: a verse event for
+// each verse and a summary event at the end, like the SSE variant.
+type VerseStreamLine struct {
+	Event string `json:"event" enum:"verse,summary" doc:"Tipo de evento"`
+	Data  any    `json:"data" doc:"El versículo (Verse) o el resumen final (VerseStreamSummary)"`
+}
+
+// streamVerses sends verses with send and returns the summary to end the
+// stream with.
+type streamVerses[I any] func(ctx context.Context, input *I, send func(Verse) error) (VerseStreamSummary, error)
+
+// finishStream runs produce and turns a failure into the summary's error.
+// Errors caused by the client going away are not logged, since there is
+// nobody left to tell.
+func finishStream[I any](ctx context.Context, input *I, produce streamVerses[I], send func(Verse) error) VerseStreamSummary {
+	count := 0
+	summary, err := produce(ctx, input, func(v Verse) error {
+		if err := send(v); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	summary.Count = count
+	if err != nil {
+		if ctx.Err() == nil {
+			requestLogger(ctx).ErrorContext(ctx, "error while streaming verses", "error", err)
+		}
+		summary.Error = "internal error"
+	}
+	return summary
+}
+
+// registerVerseStream registers the SSE and NDJSON variants of op, whose
+// paths and operation IDs get /stream and /ndjson suffixes.
+func registerVerseStream[I any](api huma.API, op huma.Operation, produce streamVerses[I]) {
+	op.Metadata = map[string]any{streamingOperation: true}
+
+	sseOp := op
+	sseOp.OperationID += "-stream"
+	sseOp.Path += "/stream"
+	sseOp.Description += " Los versículos se envían como eventos `verse` de Server-Sent Events a medida que se leen, seguidos de un evento `summary`."
+	sse.Register(api, sseOp, map[string]any{
+		"verse":   Verse{},
+		"summary": VerseStreamSummary{},
+	}, func(ctx context.Context, input *I, send sse.Sender) {
+		summary := finishStream(ctx, input, produce, func(v Verse) error {
+			return send.Data(v)
+		})
+		if ctx.Err() == nil {
+			send.Data(summary)
+		}
+	})
+
+	ndjsonOp := op
+	ndjsonOp.OperationID += "-ndjson"
+	ndjsonOp.Path += "/ndjson"
+	ndjsonOp.Description += " Cada línea de la respuesta es un objeto JSON: un evento `verse` por versículo, a medida que se leen, y un evento `summary` al final."
+	ndjsonOp.Responses = map[string]*huma.Response{
+		"200": {
+			Description: "Versículos en formato NDJSON",
+			Content: map[string]*huma.MediaType{
+				"application/x-ndjson": {
+					Schema: api.OpenAPI().Components.Schemas.Schema(reflect.TypeFor[VerseStreamLine](), true, ""),
+				},
+			},
+		},
+	}
+	huma.Register(api, ndjsonOp, func(ctx context.Context, input *I) (*huma.StreamResponse, error) {
+		return &huma.StreamResponse{
+			Body: func(hctx huma.Context) {
+				hctx.SetHeader("Content-Type", "application/x-ndjson")
+				w := hctx.BodyWriter()
+				encoder := json.NewEncoder(w)
+				write := func(line VerseStreamLine) error {
+					rw, ok := w.(http.ResponseWriter)
+					if ok {
+						http.NewResponseController(rw).SetWriteDeadline(time.Now().Add(sse.WriteTimeout))
+					}
+					if err := encoder.Encode(line); err != nil || !ok {
+						return err
+					}
+					return http.NewResponseController(rw).Flush()
+				}
+				summary := finishStream(ctx, input, produce, func(v Verse) error {
+					return write(VerseStreamLine{Event: "verse", Data: v})
+				})
+				if ctx.Err() == nil {
+					write(VerseStreamLine{Event: "summary", Data: summary})
+				}
+			},
+		}, nil
+	})
+}
+
+// streamRange returns the producer of a range stream for the passage that
+// passageOf builds from its input.
+func streamRange[I any](db *sqlx.DB, passageOf func(*I) (passage, verseSelection)) streamVerses[I] {
+	return func(ctx context.Context, input *I, send func(Verse) error) (VerseStreamSummary, error) {
+		p, fields := passageOf(input)
+		return VerseStreamSummary{Passage: p.String()}, streamPassage(ctx, db, p, fields, send)
+	}
+}
+
+// registerStreamRoutes adds the streaming variants of the range and search
+// operations.
+func registerStreamRoutes(api huma.API, db *sqlx.DB) {
+	registerVerseStream(api, huma.Operation{
+		OperationID: "get-verses-to-verse",
+		Method:      http.MethodGet,
+		Path:        "/api/books/{bookId}/verses/from/{startChapterNumber}/to/{endChapterNumber}/verse/{endVerseNumber}",
+		Summary:     "Transmitir versículos entre capítulos (límite por versículo final)",
+		Description: "Transmite todos los versículos desde un capítulo inicial hasta un capítulo final, incluyendo solo hasta el versículo especificado en el último capítulo.",
+		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeRead),
+	}, streamRange(db, func(input *ChapterToChapterVersesRequest) (passage, verseSelection) {
+		return passage{
+			BookID:       input.BookId,
+			StartChapter: int(input.StartChapterNumber),
+			EndChapter:   int(input.EndChapterNumber),
+			EndVerse:     int(input.EndVerseNumber),
+		}, input.selection()
+	}))
+
+	registerVerseStream(api, huma.Operation{
+		OperationID: "get-verse-range",
+		Method:      http.MethodGet,
+		Path:        "/api/books/{bookId}/verses/from/{startChapterNumber}/verse/{startVerseNumber}/to/{endChapterNumber}/verse/{endVerseNumber}",
+		Summary:     "Transmitir versículos entre capítulo y versículo inicial y final",
+		Description: "Transmite los versículos que se encuentran entre un capítulo y versículo inicial y un capítulo y versículo final, respetando ambos límites.",
+		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeRead),
+	}, streamRange(db, func(input *VerseRangeRequest) (passage, verseSelection) {
+		return passage{
+			BookID:       input.BookId,
+			StartChapter: int(input.StartChapterNumber),
+			StartVerse:   int(input.StartVerseNumber),
+			EndChapter:   int(input.EndChapterNumber),
+			EndVerse:     int(input.EndVerseNumber),
+		}, input.selection()
+	}))
+
+	registerVerseStream(api, huma.Operation{
+		OperationID: "get-chapter-range",
+		Method:      http.MethodGet,
+		Path:        "/api/books/{bookId}/verses/from/chapter/{startChapterNumber}/to/chapter/{endChapterNumber}",
+		Summary:     "Transmitir versículos entre capítulos",
+		Description: "Transmite todos los versículos que se encuentran entre dos capítulos específicos del mismo libro.",
+		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeRead),
+	}, streamRange(db, func(input *ChapterRangeRequest) (passage, verseSelection) {
+		return passage{
+			BookID:       input.BookId,
+			StartChapter: int(input.StartChapterNumber),
+			EndChapter:   int(input.EndChapterNumber),
+		}, input.selection()
+	}))
+
+	registerVerseStream(api, huma.Operation{
+		OperationID: "search-verses",
+		Method:      http.MethodGet,
+		Path:        "/api/verses/search",
+		Summary:     "Transmitir los resultados de una búsqueda",
+		Description: "Transmite todos los versículos que contengan el texto especificado.",
+		Tags:        []string{"Verses"},
+		Security:    requireScope(ScopeSearch),
+	}, func(ctx context.Context, input *SearchRequest, send func(Verse) error) (VerseStreamSummary, error) {
+		count := 0
+		err := streamSearch(ctx, db, input.Query, input.Mode, input.selection(), func(v Verse) error {
+			count++
+			return send(v)
+		})
+		searchResults.WithLabelValues(input.Mode).Observe(float64(count))
+		if err != nil {
+			return VerseStreamSummary{Query: input.Query}, err
+		}
+		suggestions, err := didYouMean(ctx, db, input.Query, input.Mode, count)
+		return VerseStreamSummary{Query: input.Query, Suggestions: suggestions}, err
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates every span in the server. Until setupTracing installs a
+// provider it is a no-op that still carries incoming trace context.
+var tracer = otel.Tracer("github.com/samueldelacruz/spanish-bible-api-demo")
+
+// setupTracing installs the W3C trace-context propagator and, when an
+// exporter is configured, a tracer provider that sends spans to it. The
+// returned function flushes pending spans and must be called on shutdown.
+//
+// The OTLP exporter reads its endpoint, headers and TLS settings from the
+// standard OTEL_EXPORTER_OTLP_* environment variables.
+func setupTracing(ctx context.Context, cfg TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Exporter != "otlp" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating OTLP exporter: %v", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(buildCommit()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error while creating trace resource: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	tracer = provider.Tracer("github.com/samueldelacruz/spanish-bible-api-demo")
+	return provider.Shutdown, nil
+}
+
+// traceRequests is chi middleware that continues the trace from the
+// request's traceparent header, if any, and wraps the request in a server
+// span. nameSpan renames the span after the matched Huma operation.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// nameSpan is Huma middleware that names the request's server span after the
+// matched operation and records its route.
+func nameSpan(ctx huma.Context, next func(huma.Context)) {
+	op := ctx.Operation()
+	span := trace.SpanFromContext(ctx.Context())
+	span.SetName(op.OperationID)
+	span.SetAttributes(semconv.HTTPRoute(op.Path))
+	next(ctx)
+}
+
+// startQuerySpan starts a child span for a named SQL query.
+func startQuerySpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "db "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameSQLite,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// endQuerySpan records the number of rows read and any error, and ends the
+// span.
+func endQuerySpan(span trace.Span, rows int, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		rows = 0
+	} else if err != nil {
+		rows = 0
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(semconv.DBResponseReturnedRows(rows))
+	span.End()
+}
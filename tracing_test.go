@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans makes tracer record every span until the test ends.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	saved, savedPropagator := tracer, otel.GetTextMapPropagator()
+	tracer = provider.Tracer("test")
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		tracer = saved
+		otel.SetTextMapPropagator(savedPropagator)
+	})
+	return recorder
+}
+
+func findSpan(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %q", name)
+	return nil
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTraceRequest(t *testing.T) {
+	db := newTestDB(t)
+	recorder := recordSpans(t)
+	router := chi.NewMux()
+	router.Use(traceRequests)
+	api := humachi.New(router, huma.DefaultConfig("test", apiVersion))
+	api.UseMiddleware(nameSpan)
+	registerBatchRoutes(api, db)
+
+	const traceId, parentId = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req := httptest.NewRequest(http.MethodPost, "/api/verses/batch", strings.NewReader(`{"refs": ["Génesis 1:1-2"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceId+"-"+parentId+"-01")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Code, res.Body)
+	}
+
+	spans := recorder.Ended()
+	op := findSpan(t, spans, "get-verses-batch")
+	if got := op.SpanContext().TraceID().String(); got != traceId {
+		t.Errorf("operation span trace ID = %s, want the traceparent's %s", got, traceId)
+	}
+	if got := op.Parent().SpanID().String(); got != parentId {
+		t.Errorf("operation span parent = %s, want the traceparent's %s", got, parentId)
+	}
+	if op.SpanKind() != trace.SpanKindServer {
+		t.Errorf("operation span kind = %v, want server", op.SpanKind())
+	}
+	if got := spanAttribute(op, "http.route").AsString(); got != "/api/verses/batch" {
+		t.Errorf("http.route = %q", got)
+	}
+	if got := spanAttribute(op, "http.response.status_code").AsInt64(); got != http.StatusOK {
+		t.Errorf("http.response.status_code = %d", got)
+	}
+
+	query := findSpan(t, spans, "db verses_batch")
+	if query.Parent().SpanID() != op.SpanContext().SpanID() {
+		t.Errorf("query span is not a child of the operation span")
+	}
+	if got := spanAttribute(query, "db.operation.name").AsString(); got != "verses_batch" {
+		t.Errorf("db.operation.name = %q", got)
+	}
+	if got := spanAttribute(query, "db.response.returned_rows").AsInt64(); got != 2 {
+		t.Errorf("db.response.returned_rows = %d, want 2", got)
+	}
+}
+
+func TestTraceQueryError(t *testing.T) {
+	db := newTestDB(t)
+	recorder := recordSpans(t)
+
+	rows := []string{}
+	if err := dbSelect(context.Background(), db, "broken", &rows, `SELECT nothing FROM nowhere`); err == nil {
+		t.Fatal("expected an error")
+	}
+	span := findSpan(t, recorder.Ended(), "db broken")
+	if span.Status().Code != codes.Error {
+		t.Errorf("status = %v, want error", span.Status().Code)
+	}
+	if got := spanAttribute(span, "db.response.returned_rows").AsInt64(); got != 0 {
+		t.Errorf("db.response.returned_rows = %d, want 0", got)
+	}
+}